@@ -0,0 +1,648 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/niczy/wt/internal/commands"
+	"github.com/niczy/wt/internal/shell"
+)
+
+// runFunc runs a command with its arguments, after flags are parsed
+type runFunc func(args []string, format commands.Format) error
+
+// command is a wt subcommand
+type command struct {
+	name    string
+	aliases []string
+	// args is the argument synopsis shown in help, e.g. "<name>"
+	args    string
+	summary string
+	// legacy is the form of the original flag-based CLI that still runs
+	// the command, shown in help
+	legacy string
+	// minArgs and maxArgs bound the number of arguments; maxArgs -1
+	// means no limit
+	minArgs, maxArgs int
+	// output commands accept --json and --porcelain
+	output bool
+	// raw commands get their arguments without flag parsing
+	raw    bool
+	hidden bool
+	// setup defines the command's flags on fs and returns the function
+	// that runs it
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// commandList holds all commands in the order help lists them. It is
+// filled in by init since the help command refers to it.
+var commandList []*command
+
+func init() {
+	commandList = []*command{
+		{
+			name:    "create",
+			args:    "<name>",
+			summary: "Create a new worktree with the given name",
+			legacy:  "-c <name>",
+			minArgs: 1, maxArgs: 1,
+			output: true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				from := fs.String("from", "", "Start the new branch at `ref` (branch, tag, commit or remote ref)")
+				branch := fs.String("branch", "", "Use `branch` instead of <name> (directory stays {repo}-<name>)")
+				return func(args []string, format commands.Format) error {
+					return commands.Create(args[0], commands.CreateOptions{
+						From:   *from,
+						Branch: *branch,
+						Format: format,
+					})
+				}
+			},
+		},
+		{
+			name:    "go",
+			args:    "<query>...",
+			summary: "Navigate to a worktree (fuzzy search on directory or branch)",
+			legacy:  "<query>",
+			minArgs: 1, maxArgs: -1,
+			output: true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				color := colorFlag(fs)
+				root, subdir := subdirFlags(fs)
+				noInput := noInputFlag(fs)
+				return func(args []string, format commands.Format) error {
+					// All arguments form one query, e.g. wt go api !old
+					return commands.Navigate(strings.Join(args, " "), commands.NavigateOptions{
+						Format:  format,
+						Color:   *color,
+						Root:    *root,
+						Subdir:  *subdir,
+						NoInput: *noInput,
+					})
+				}
+			},
+		},
+		{
+			name:    "rm",
+			aliases: []string{"remove", "delete"},
+			args:    "<query>...",
+			summary: "Delete worktrees, one per query (fuzzy search)",
+			legacy:  "-d <query>",
+			minArgs: 1, maxArgs: -1,
+			output: true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				color := colorFlag(fs)
+				force := fs.Bool("force", false, "Delete even with uncommitted changes, untracked files, stashes or unpushed commits")
+				deleteBranch := fs.Bool("delete-branch", false, "Also delete the branch if it is merged into the base")
+				forceDeleteBranch := fs.Bool("D", false, "Also delete the branch, merged or not")
+				allMatching := fs.Bool("all-matching", false, "Delete every worktree a query matches instead of asking")
+				yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+				noInput := noInputFlag(fs)
+				return func(args []string, format commands.Format) error {
+					return commands.Delete(args, commands.DeleteOptions{
+						Format:            format,
+						Color:             *color,
+						Force:             *force,
+						DeleteBranch:      *deleteBranch,
+						ForceDeleteBranch: *forceDeleteBranch,
+						AllMatching:       *allMatching,
+						Yes:               *yes,
+						NoInput:           *noInput,
+					})
+				}
+			},
+		},
+		{
+			name:    "prune",
+			summary: "Delete worktrees whose branch is merged or whose upstream is gone",
+			output:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				base := fs.String("base", "", "Check branches are merged into `ref` instead of base_ref")
+				stale := fs.Int("stale", 0, "Also prune worktrees without a commit or visit in `days`")
+				dryRun := fs.Bool("dry-run", false, "List what would be pruned without deleting anything")
+				yes := fs.Bool("yes", false, "Delete without asking for confirmation")
+				force := fs.Bool("force", false, "Delete even with uncommitted changes, untracked files, stashes or unpushed commits")
+				deleteBranch := fs.Bool("delete-branch", false, "Also delete the branches merged into the base")
+				noInput := noInputFlag(fs)
+				return func(args []string, format commands.Format) error {
+					return commands.Prune(commands.PruneOptions{
+						Format:       format,
+						Base:         *base,
+						StaleDays:    *stale,
+						DryRun:       *dryRun,
+						Yes:          *yes,
+						Force:        *force,
+						DeleteBranch: *deleteBranch,
+						NoInput:      *noInput,
+					})
+				}
+			},
+		},
+		{
+			name:    "trash",
+			args:    "[ls]",
+			summary: "List deleted worktrees that wt undo can restore",
+			maxArgs: 1,
+			output:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					if len(args) == 1 && args[0] != "ls" {
+						return &commands.Error{
+							Code: commands.CodeInvalidInput,
+							Err:  fmt.Errorf("unknown trash command '%s' (supported: ls)", args[0]),
+						}
+					}
+					return commands.TrashList(commands.TrashOptions{Format: format})
+				}
+			},
+		},
+		{
+			name:    "undo",
+			args:    "[name]",
+			summary: "Restore the last deleted worktree, or the one named name",
+			maxArgs: 1,
+			output:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					name := ""
+					if len(args) == 1 {
+						name = args[0]
+					}
+					return commands.Undo(name, commands.TrashOptions{Format: format})
+				}
+			},
+		},
+		{
+			name:    "ls",
+			aliases: []string{"list"},
+			summary: "List all worktrees with branch, status, upstream and last commit",
+			legacy:  "-l",
+			output:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				short := fs.Bool("short", false, "Only print worktree paths")
+				return func(args []string, format commands.Format) error {
+					return commands.List(commands.ListOptions{Short: *short, Format: format})
+				}
+			},
+		},
+		{
+			name:    "back",
+			summary: "Go back to the previously visited worktree, like cd -",
+			legacy:  "-",
+			output:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				root, subdir := subdirFlags(fs)
+				return func(args []string, format commands.Format) error {
+					return commands.Back(commands.HistoryOptions{Format: format, Root: *root, Subdir: *subdir})
+				}
+			},
+		},
+		{
+			name:    "history",
+			args:    "[N]",
+			summary: "List recently visited worktrees, or go to entry N",
+			legacy:  "--history [N]",
+			maxArgs: 1,
+			output:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				root, subdir := subdirFlags(fs)
+				return func(args []string, format commands.Format) error {
+					index := 0
+					if len(args) > 0 {
+						var err error
+						if index, err = strconv.Atoi(args[0]); err != nil {
+							return &commands.Error{
+								Code: commands.CodeInvalidInput,
+								Err:  fmt.Errorf("history takes an optional entry number, got '%s'", args[0]),
+							}
+						}
+					}
+					return commands.History(index, commands.HistoryOptions{Format: format, Root: *root, Subdir: *subdir})
+				}
+			},
+		},
+		{
+			name:    "forget",
+			args:    "<name>",
+			summary: "Remove a worktree (by name or path) from the navigation history",
+			legacy:  "--forget <name>",
+			minArgs: 1, maxArgs: 1,
+			output: true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					return commands.Forget(args[0], commands.ForgetOptions{Format: format})
+				}
+			},
+		},
+		{
+			name:    "config",
+			args:    "[key]",
+			summary: "Show the effective configuration, or only the value of key",
+			maxArgs: 1,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					if len(args) == 1 {
+						return commands.ShowConfigValue(args[0])
+					}
+					return commands.ShowConfig()
+				}
+			},
+		},
+		{
+			name:    "init",
+			args:    "<shell>",
+			summary: "Print the shell integration for bash, zsh, fish, pwsh or nu",
+			minArgs: 1, maxArgs: 1,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					return commands.Init(args[0])
+				}
+			},
+		},
+		{
+			name:    "completion",
+			args:    "<shell>",
+			summary: "Print the completion script for bash, zsh or fish",
+			minArgs: 1, maxArgs: 1,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					return commands.Completion(args[0])
+				}
+			},
+		},
+		{
+			name:    "help",
+			args:    "[command]",
+			summary: "Show help for wt or a command",
+			maxArgs: 1,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					if len(args) == 0 {
+						printUsage(os.Stdout)
+						return nil
+					}
+					cmd, ok := lookupCommand(args[0])
+					if !ok {
+						return &commands.Error{
+							Code: commands.CodeInvalidInput,
+							Err:  fmt.Errorf("unknown command '%s'", args[0]),
+						}
+					}
+					printCommandHelp(os.Stdout, cmd)
+					return nil
+				}
+			},
+		},
+		{
+			name:    shell.CompleteCommand,
+			maxArgs: -1,
+			raw:     true,
+			hidden:  true,
+			setup: func(fs *flag.FlagSet) runFunc {
+				return func(args []string, format commands.Format) error {
+					return commands.Complete(args, commands.CompleteOptions{
+						Commands: completionCommands(),
+						Flags:    legacyCompletionFlags(),
+					})
+				}
+			},
+		},
+	}
+}
+
+// colorFlag defines --color for commands that may show the picker
+func colorFlag(fs *flag.FlagSet) *string {
+	return fs.String("color", "", "Highlight matched characters (`when`: auto, always or never)")
+}
+
+// noInputFlag defines --no-input for commands that may prompt
+func noInputFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("no-input", false, "Never prompt: fail on ambiguous matches and delete only with --yes")
+}
+
+// subdirFlags defines --root and --subdir for commands that enter a
+// worktree
+func subdirFlags(fs *flag.FlagSet) (root, subdir *bool) {
+	root = fs.Bool("root", false, "Enter the worktree root instead of the current subdirectory")
+	subdir = fs.Bool("subdir", false, "Enter the current subdirectory even if preserve_subdir is off")
+	return root, subdir
+}
+
+// lookupCommand finds a command by name or alias
+func lookupCommand(name string) (*command, bool) {
+	for _, cmd := range commandList {
+		if cmd.name == name {
+			return cmd, true
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// newFlagSet returns the flag set of cmd with its run function and, for
+// output commands, the --json and --porcelain flags
+func newFlagSet(cmd *command) (fs *flag.FlagSet, run runFunc, jsonFlag, porcelainFlag *bool) {
+	fs = flag.NewFlagSet("wt "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	run = cmd.setup(fs)
+	if cmd.output {
+		jsonFlag = fs.Bool("json", false, "Write results and errors as JSON to stdout")
+		porcelainFlag = fs.Bool("porcelain", false, "Write results and errors as stable \"key value\" lines")
+	}
+	return fs, run, jsonFlag, porcelainFlag
+}
+
+// runCommand parses args for cmd and runs it, returning the exit status
+func runCommand(cmd *command, args []string) int {
+	fs, run, jsonFlag, porcelainFlag := newFlagSet(cmd)
+
+	var err error
+	if !cmd.raw {
+		args, err = parseArgs(fs, args)
+		if errors.Is(err, flag.ErrHelp) {
+			printCommandHelp(os.Stdout, cmd)
+			return 0
+		}
+	}
+
+	format := commands.FormatText
+	if cmd.output {
+		switch {
+		case *jsonFlag && *porcelainFlag:
+			err = fmt.Errorf("--json and --porcelain cannot be used together")
+		case *jsonFlag:
+			format = commands.FormatJSON
+		case *porcelainFlag:
+			format = commands.FormatPorcelain
+		}
+	}
+	if err == nil && (len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs)) {
+		err = fmt.Errorf("usage: wt %s", strings.TrimSpace(cmd.name+" "+cmd.args))
+	}
+	if err != nil {
+		err = &commands.Error{
+			Code: commands.CodeInvalidInput,
+			Err:  fmt.Errorf("%v (see 'wt %s -h')", err, cmd.name),
+		}
+		commands.ReportError(format, err)
+		return commands.ExitCode(err)
+	}
+
+	if err := run(args, format); err != nil {
+		commands.ReportError(format, err)
+		return commands.ExitCode(err)
+	}
+	return 0
+}
+
+// parseArgs parses the flags in args, which may come before, between or
+// after the arguments, and returns the arguments. "--" ends the flags.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Parse stops after "--" or at the first argument; only the
+		// latter may be followed by more flags
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// legacyFlag is an action flag of the original CLI, kept as an alias for
+// the command it stands for
+type legacyFlag struct {
+	name    string
+	command string
+	// takesValue is set when the flag's value is the command's first
+	// argument, as in -c <name>
+	takesValue bool
+}
+
+var legacyFlags = []legacyFlag{
+	{"c", "create", true},
+	{"d", "rm", true},
+	{"l", "ls", false},
+	{"history", "history", false},
+	{"forget", "forget", true},
+}
+
+// legacyValueFlags are the other flags of the original CLI that take a
+// value, so their value isn't mistaken for an argument
+var legacyValueFlags = map[string]bool{"from": true, "branch": true, "color": true}
+
+// rewriteLegacy turns an invocation of the original flag-based CLI into
+// the equivalent command: "wt -c x --from y" becomes "wt create x --from
+// y", "wt -" becomes "wt back", "wt feat" becomes "wt go feat" and plain
+// "wt" becomes "wt ls". Invocations that start with a command are
+// returned as is.
+func rewriteLegacy(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{"ls"}, nil
+	}
+	if _, ok := lookupCommand(args[0]); ok {
+		return args, nil
+	}
+	if args[0] == "-" {
+		return append([]string{"back"}, args[1:]...), nil
+	}
+
+	var action *legacyFlag
+	var actionArg []string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, hasValue := flagName(arg)
+		var lf *legacyFlag
+		for j := range legacyFlags {
+			if legacyFlags[j].name == name {
+				lf = &legacyFlags[j]
+			}
+		}
+		if lf == nil {
+			rest = append(rest, arg)
+			if legacyValueFlags[name] && !hasValue && i+1 < len(args) {
+				rest = append(rest, args[i+1])
+				i++
+			}
+			continue
+		}
+
+		if action != nil {
+			return nil, fmt.Errorf("-%s and -%s cannot be used together", action.name, lf.name)
+		}
+		action = lf
+		if lf.takesValue {
+			switch {
+			case hasValue:
+				actionArg = []string{arg[strings.Index(arg, "=")+1:]}
+			case i+1 < len(args):
+				actionArg = []string{args[i+1]}
+				i++
+			default:
+				return nil, fmt.Errorf("flag needs an argument: -%s", lf.name)
+			}
+		}
+	}
+
+	if action != nil {
+		return append(append([]string{action.command}, actionArg...), rest...), nil
+	}
+
+	// Without an action flag, the first argument names a command or
+	// starts a query; with no arguments at all, worktrees are listed
+	for i := 0; i < len(rest); i++ {
+		name, hasValue := flagName(rest[i])
+		if name == "h" || name == "help" {
+			return []string{"help"}, nil
+		}
+		if name == "" {
+			if _, ok := lookupCommand(rest[i]); ok {
+				return append(append([]string{rest[i]}, rest[:i]...), rest[i+1:]...), nil
+			}
+			return append([]string{"go"}, rest...), nil
+		}
+		if legacyValueFlags[name] && !hasValue {
+			i++
+		}
+	}
+	return append([]string{"ls"}, rest...), nil
+}
+
+// flagName returns the name of the flag arg spells, such as "c" for "-c"
+// or "from" for "--from=x", and whether it carries its value. It returns
+// "" for arguments that aren't flags.
+func flagName(arg string) (name string, hasValue bool) {
+	if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+		return "", false
+	}
+	name = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+	if i := strings.Index(name, "="); i >= 0 {
+		return name[:i], true
+	}
+	return name, false
+}
+
+// printUsage writes the top-level help
+func printUsage(w io.Writer) {
+	fmt.Fprint(w, "wt - Git Worktree Manager\n\nUsage:\n  wt <command> [flags] [args]\n  wt <query>        Same as wt go <query>\n\nCommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commandList {
+		if !cmd.hidden {
+			fmt.Fprintf(tw, "  %s\t%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+		}
+	}
+	tw.Flush()
+	fmt.Fprint(w, usage)
+}
+
+// printCommandHelp writes the help for cmd: its synopsis, aliases and flags
+func printCommandHelp(w io.Writer, cmd *command) {
+	fmt.Fprintf(w, "Usage: wt %s [flags]\n\n%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+
+	var aliases []string
+	for _, alias := range cmd.aliases {
+		aliases = append(aliases, "wt "+alias)
+	}
+	if cmd.legacy != "" {
+		aliases = append(aliases, "wt "+cmd.legacy)
+	}
+	if len(aliases) > 0 {
+		fmt.Fprintf(w, "\nAliases: %s\n", strings.Join(aliases, ", "))
+	}
+
+	fs, _, _, _ := newFlagSet(cmd)
+	var lines []string
+	fs.VisitAll(func(f *flag.Flag) {
+		arg, usage := flag.UnquoteUsage(f)
+		if arg != "" {
+			arg = " <" + arg + ">"
+		}
+		dashes := "--"
+		if len(f.Name) == 1 {
+			dashes = "-"
+		}
+		lines = append(lines, fmt.Sprintf("  %s%s%s\t%s", dashes, f.Name, arg, usage))
+	})
+	if len(lines) > 0 {
+		fmt.Fprintln(w, "\nFlags:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, line := range lines {
+			fmt.Fprintln(tw, line)
+		}
+		tw.Flush()
+	}
+}
+
+// completionFlags describes the flags of fs for completion
+func completionFlags(fs *flag.FlagSet) []commands.CompletionFlag {
+	var flags []commands.CompletionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		_, usage := flag.UnquoteUsage(f)
+		flags = append(flags, commands.CompletionFlag{
+			Name:       f.Name,
+			Usage:      usage,
+			TakesValue: !ok || !b.IsBoolFlag(),
+		})
+	})
+	return flags
+}
+
+// completionCommands describes the visible commands for completion
+func completionCommands() []commands.CompletionCommand {
+	var result []commands.CompletionCommand
+	for _, cmd := range commandList {
+		if cmd.hidden {
+			continue
+		}
+		fs, _, _, _ := newFlagSet(cmd)
+		result = append(result, commands.CompletionCommand{
+			Name:    cmd.name,
+			Aliases: cmd.aliases,
+			Summary: cmd.summary,
+			Flags:   completionFlags(fs),
+		})
+	}
+	return result
+}
+
+// legacyCompletionFlags describes the flags accepted before a command is
+// named: the legacy action flags and the flags of wt go
+func legacyCompletionFlags() []commands.CompletionFlag {
+	var flags []commands.CompletionFlag
+	for _, lf := range legacyFlags {
+		cmd, _ := lookupCommand(lf.command)
+		flags = append(flags, commands.CompletionFlag{
+			Name:       lf.name,
+			Usage:      cmd.summary,
+			TakesValue: lf.takesValue,
+			Command:    cmd.name,
+		})
+	}
+	goCmd, _ := lookupCommand("go")
+	fs, _, _, _ := newFlagSet(goCmd)
+	return append(flags, completionFlags(fs)...)
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"flag"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -136,7 +137,7 @@ func TestIntegration_Help(t *testing.T) {
 	expectedStrings := []string{
 		"wt - Git Worktree Manager",
 		"wt -c <name>",
-		"wt -d <name>",
+		"wt -d <query>",
 		"wt -l",
 		"WT_HOME",
 	}
@@ -169,6 +170,9 @@ func TestIntegration_ErrorCases(t *testing.T) {
 		if err == nil {
 			t.Error("expected error for non-matching navigate")
 		}
+		if code := cmd.ProcessState.ExitCode(); code != 5 {
+			t.Errorf("expected exit code 5, got %d", code)
+		}
 		if !strings.Contains(string(output), "no worktrees found") {
 			t.Errorf("expected 'no worktrees found' error, got: %s", output)
 		}
@@ -182,6 +186,9 @@ func TestIntegration_ErrorCases(t *testing.T) {
 		if err == nil {
 			t.Error("expected error for non-matching delete")
 		}
+		if code := cmd.ProcessState.ExitCode(); code != 5 {
+			t.Errorf("expected exit code 5, got %d", code)
+		}
 		if !strings.Contains(string(output), "no worktrees found") {
 			t.Errorf("expected 'no worktrees found' error, got: %s", output)
 		}
@@ -200,8 +207,145 @@ func TestIntegration_ErrorCases(t *testing.T) {
 		if err == nil {
 			t.Error("expected error when creating worktree outside git repo")
 		}
+		if code := cmd.ProcessState.ExitCode(); code != 11 {
+			t.Errorf("expected exit code 11, got %d", code)
+		}
 		if !strings.Contains(string(output), "not in a git repository") {
 			t.Errorf("expected 'not in a git repository' error, got: %s", output)
 		}
 	})
 }
+
+func TestIntegration_ShellInitBash(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("skipping shell integration test: bash not found")
+	}
+
+	// Spaces and '=' in the path used to break the grep/cut wrapper
+	tmpDir := filepath.Join(t.TempDir(), "wt home=1")
+	binDir := filepath.Join(tmpDir, "bin")
+	binaryPath := filepath.Join(binDir, "wt")
+	buildCmd := exec.Command("go", "build", "-o", binaryPath, ".")
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build wt: %v\n%s", err, output)
+	}
+
+	repo := filepath.Join(tmpDir, "repo")
+	wtPath := filepath.Join(tmpDir, "repo-feature")
+	for _, args := range [][]string{
+		{"init", "-q", repo},
+		{"-C", repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+		{"-C", repo, "worktree", "add", "-q", "-b", "feature", wtPath},
+	} {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+
+	cmd := exec.Command(bash, "--norc", "--noprofile", "-c", `eval "$(wt init bash)" && wt feature && pwd`)
+	cmd.Dir = repo
+	cmd.Env = append(os.Environ(),
+		"PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"WT_HOME="+tmpDir,
+		"WT_DATA_DIR="+filepath.Join(tmpDir, "data"),
+		"WT_CONFIG="+filepath.Join(tmpDir, "config.toml"),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("wrapped navigate failed: %v\n%s", err, output)
+	}
+	if got := strings.TrimSpace(string(output)); got != wtPath {
+		t.Errorf("expected the wrapper to cd to %s, got: %s", wtPath, got)
+	}
+}
+
+func TestRewriteLegacy(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "ls", false},
+		{[]string{"-l", "--short"}, "ls --short", false},
+		{[]string{"--json"}, "ls --json", false},
+		{[]string{"-c", "x", "--from", "main"}, "create x --from main", false},
+		{[]string{"--from", "main", "-c=x"}, "create x --from main", false},
+		{[]string{"-d", "api", "!old"}, "rm api !old", false},
+		{[]string{"--history", "2"}, "history 2", false},
+		{[]string{"--forget", "x"}, "forget x", false},
+		{[]string{"-", "--root"}, "back --root", false},
+		{[]string{"feat"}, "go feat", false},
+		{[]string{"--color", "never", "feat"}, "go --color never feat", false},
+		{[]string{"--json", "ls"}, "ls --json", false},
+		{[]string{"-h"}, "help", false},
+		{[]string{"create", "-c"}, "create -c", false},
+		{[]string{"-c", "x", "-d", "y"}, "", true},
+		{[]string{"-c"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := rewriteLegacy(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("rewriteLegacy(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("rewriteLegacy(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	root := fs.Bool("root", false, "")
+	color := fs.String("color", "", "")
+
+	args, err := parseArgs(fs, []string{"api", "--root", "!old", "--color", "never", "--", "--x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(args, " ") != "api !old --x" || !*root || *color != "never" {
+		t.Errorf("got args %q, root %v, color %q", args, *root, *color)
+	}
+}
+
+func TestIntegration_Subcommands(t *testing.T) {
+	tmpDir := t.TempDir()
+	binaryPath := filepath.Join(tmpDir, "wt")
+	buildCmd := exec.Command("go", "build", "-o", binaryPath, ".")
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build wt: %v\n%s", err, output)
+	}
+
+	t.Run("CommandHelp", func(t *testing.T) {
+		output, err := exec.Command(binaryPath, "create", "-h").CombinedOutput()
+		if err != nil {
+			t.Fatalf("create -h failed: %v\n%s", err, output)
+		}
+		for _, expected := range []string{"Usage: wt create <name>", "wt -c <name>", "--from <ref>"} {
+			if !strings.Contains(string(output), expected) {
+				t.Errorf("expected '%s' in create help, got: %s", expected, output)
+			}
+		}
+	})
+
+	t.Run("ConflictingLegacyFlags", func(t *testing.T) {
+		output, err := exec.Command(binaryPath, "-c", "x", "-d", "y").CombinedOutput()
+		if err == nil {
+			t.Error("expected -c with -d to fail")
+		}
+		if !strings.Contains(string(output), "cannot be used together") {
+			t.Errorf("expected a conflict error, got: %s", output)
+		}
+	})
+
+	t.Run("ExtraArguments", func(t *testing.T) {
+		output, err := exec.Command(binaryPath, "ls", "extra").CombinedOutput()
+		if err == nil {
+			t.Error("expected ls with an argument to fail")
+		}
+		if !strings.Contains(string(output), "usage: wt ls") {
+			t.Errorf("expected a usage error, got: %s", output)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"os"
+	"strings"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/fuzzy"
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/picker"
+)
+
+// useColor reports whether output written to f should be colored. The
+// color setting is "always", "never" or "auto"; auto colors terminals
+// unless NO_COLOR is set or TERM is "dumb".
+func useColor(cfg *config.Config, f *os.File) bool {
+	switch cfg.Get(config.KeyColor) {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return picker.IsTerminal(f)
+}
+
+// highlight wraps the runes of text at positions in bold green
+func highlight(text string, positions []int) string {
+	if len(positions) == 0 {
+		return text
+	}
+	var b strings.Builder
+	i := 0
+	for _, r := range text {
+		if len(positions) > 0 && positions[0] == i {
+			b.WriteString("\x1b[1;32m")
+			b.WriteRune(r)
+			b.WriteString("\x1b[0m")
+			positions = positions[1:]
+		} else {
+			b.WriteRune(r)
+		}
+		i++
+	}
+	return b.String()
+}
+
+// highlightLabel returns worktreeLabel(wt) with the characters matched by
+// q highlighted in the name and branch
+func highlightLabel(q fuzzy.Query, wt git.Worktree) string {
+	_, matches := q.MatchFields(wt.Name, wt.Branch)
+	name := highlight(wt.Name, matches[0].Positions)
+	if wt.Branch == "" {
+		return name
+	}
+	return name + " [" + highlight(wt.Branch, matches[1].Positions) + "]"
+}
@@ -2,13 +2,38 @@ package commands
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/fuzzy"
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/history"
+	"github.com/niczy/wt/internal/shell"
 )
 
+// TestMain keeps the navigation history of tests out of the user's data
+// directory
+func TestMain(m *testing.M) {
+	dataDir, err := os.MkdirTemp("", "wt-data-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("WT_DATA_DIR", dataDir)
+	code := m.Run()
+	os.RemoveAll(dataDir)
+	os.Exit(code)
+}
+
 // Helper function to temporarily set WT_HOME
 func withWTHome(t *testing.T, path string, fn func()) {
 	original := os.Getenv("WT_HOME")
@@ -23,7 +48,38 @@ func withWTHome(t *testing.T, path string, fn func()) {
 	fn()
 }
 
-// Helper function to create a mock worktree directory
+// Helper function to run git in dir, failing the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+}
+
+// Helper function to create a git repository with an initial commit
+func createTestRepo(t *testing.T) string {
+	t.Helper()
+	repo := filepath.Join(t.TempDir(), "main-repo")
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		t.Fatalf("failed to create repo dir: %v", err)
+	}
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "initial")
+	return repo
+}
+
+// Helper function to add a worktree of repo at wtHome/name on a new branch
+func createTestWorktree(t *testing.T, repo, wtHome, name, branch string) string {
+	t.Helper()
+	wtPath := filepath.Join(wtHome, name)
+	runGit(t, repo, "worktree", "add", "-q", "-b", branch, wtPath)
+	return wtPath
+}
+
+// Helper function to create a directory that looks like a worktree but
+// has no git metadata behind it
 func createMockWorktree(t *testing.T, wtHome, name string) string {
 	wtPath := filepath.Join(wtHome, name)
 	gitPath := filepath.Join(wtPath, ".git")
@@ -58,7 +114,7 @@ func TestList_EmptyDir(t *testing.T) {
 
 	withWTHome(t, tmpDir, func() {
 		output := captureOutput(func() {
-			err := List()
+			err := List(ListOptions{})
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -76,13 +132,13 @@ func TestList_WithWorktrees(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Create mock worktrees
-	createMockWorktree(t, tmpDir, "repo-feature")
-	createMockWorktree(t, tmpDir, "repo-bugfix")
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, tmpDir, "repo-feature", "feature")
+	createTestWorktree(t, repo, tmpDir, "repo-bugfix", "bugfix")
 
 	withWTHome(t, tmpDir, func() {
 		output := captureOutput(func() {
-			err := List()
+			err := List(ListOptions{})
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -96,6 +152,321 @@ func TestList_WithWorktrees(t *testing.T) {
 	})
 }
 
+func TestList_Status(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repo := createTestRepo(t)
+	dirtyPath := createTestWorktree(t, repo, tmpDir, "repo-dirty", "dirty")
+	createTestWorktree(t, repo, tmpDir, "repo-clean", "clean")
+	if err := os.WriteFile(filepath.Join(dirtyPath, "new.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := List(ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		lines := strings.Split(output, "\n")
+		if !strings.HasPrefix(lines[0], "NAME") {
+			t.Errorf("expected table header, got: %s", output)
+		}
+		for _, line := range lines {
+			switch {
+			case strings.HasPrefix(line, "repo-dirty "):
+				if !strings.Contains(line, "1 untracked") || !strings.Contains(line, "initial") {
+					t.Errorf("unexpected row for dirty worktree: %s", line)
+				}
+			case strings.HasPrefix(line, "repo-clean "):
+				if !strings.Contains(line, "clean") {
+					t.Errorf("unexpected row for clean worktree: %s", line)
+				}
+			}
+		}
+	})
+}
+
+func TestList_JSON(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, tmpDir, "repo-feature", "feature")
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := List(ListOptions{Format: FormatJSON})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+
+		var result struct {
+			Worktrees []worktreeInfo `json:"worktrees"`
+		}
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, output)
+		}
+		if len(result.Worktrees) != 2 {
+			t.Fatalf("expected 2 worktrees, got %d", len(result.Worktrees))
+		}
+		for _, wt := range result.Worktrees {
+			if wt.Name == "repo-feature" {
+				if wt.Branch != "feature" || wt.Status == nil || wt.Status.Dirty || wt.Status.LastCommit == nil {
+					t.Errorf("unexpected worktree info: %+v", wt)
+				}
+				return
+			}
+		}
+		t.Errorf("expected repo-feature in output, got: %s", output)
+	})
+}
+
+func TestNavigate_Porcelain(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repo := createTestRepo(t)
+	wtPath := createTestWorktree(t, repo, tmpDir, "repo-feature", "feature")
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := Navigate("feature", NavigateOptions{Format: FormatPorcelain})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		for _, line := range []string{"worktree " + wtPath, "name repo-feature", "branch feature"} {
+			if !strings.Contains(output, line+"\n") {
+				t.Errorf("expected line %q, got: %s", line, output)
+			}
+		}
+		if strings.Contains(output, "WT_CD_PATH=") {
+			t.Errorf("expected no WT_CD_PATH in porcelain output, got: %s", output)
+		}
+	})
+}
+
+func TestReportError(t *testing.T) {
+	err := errorf(CodeNoMatch, "no worktree matching '%s' found", "x")
+
+	output := captureOutput(func() { ReportError(FormatJSON, err) })
+	var result struct {
+		Error errorInfo `json:"error"`
+	}
+	if jsonErr := json.Unmarshal([]byte(output), &result); jsonErr != nil {
+		t.Fatalf("invalid JSON: %v\n%s", jsonErr, output)
+	}
+	if result.Error.Code != CodeNoMatch || result.Error.Message != err.Error() {
+		t.Errorf("unexpected error object: %+v", result.Error)
+	}
+
+	output = captureOutput(func() { ReportError(FormatPorcelain, err) })
+	if output != "error no_match no worktree matching 'x' found\n" {
+		t.Errorf("unexpected porcelain error: %q", output)
+	}
+
+	// git's messages often span several lines; porcelain keeps them on one
+	gitErr := errorf(CodeGit, "failed to remove worktree: fatal: '/wt/x' contains modified files\nuse --force to delete it\r\n")
+	output = captureOutput(func() { ReportError(FormatPorcelain, gitErr) })
+	if output != "error git_failed failed to remove worktree: fatal: '/wt/x' contains modified files use --force to delete it\n" {
+		t.Errorf("unexpected porcelain error: %q", output)
+	}
+	output = captureOutput(func() {
+		_ = writeDeleteResults(FormatPorcelain, []*deleteTarget{{wt: git.Worktree{Path: "/wt/x", Name: "x", Repo: "/src/repo"}, err: gitErr}})
+	})
+	if !strings.Contains(output, "\nerror git_failed failed to remove worktree: fatal: '/wt/x' contains modified files use --force to delete it\n\n") {
+		t.Errorf("expected a single-line error in the porcelain result, got %q", output)
+	}
+
+	if code := ErrorCode(fmt.Errorf("wrapped: %w", err)); code != CodeNoMatch {
+		t.Errorf("expected code to survive wrapping, got %s", code)
+	}
+	if code := ErrorCode(fmt.Errorf("plain")); code != CodeUnknown {
+		t.Errorf("expected %s for uncoded error, got %s", CodeUnknown, code)
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", errorf(CodeNoMatch, "no worktree matching '%s' found", "x"))
+	if !errors.Is(err, ErrNoMatch) || errors.Is(err, ErrAmbiguous) {
+		t.Errorf("expected %v to match ErrNoMatch only", err)
+	}
+	if code := ExitCode(err); code != 3 {
+		t.Errorf("expected exit code 3 for no match, got %d", code)
+	}
+
+	// Sentinels from internal/git carry their code without an *Error
+	notARepo := fmt.Errorf("%w: exit status 128", git.ErrNotARepo)
+	if ErrorCode(notARepo) != CodeNotARepo || !errors.Is(notARepo, ErrNotARepo) {
+		t.Errorf("expected %s for %v, got %s", CodeNotARepo, notARepo, ErrorCode(notARepo))
+	}
+
+	// Git failures with a sentinel get its code rather than CodeGit
+	dirty := withCode(CodeGit, fmt.Errorf("failed to remove worktree: %w", git.ErrDirty))
+	if ErrorCode(dirty) != CodeDirty || !errors.Is(dirty, git.ErrDirty) {
+		t.Errorf("expected %s for %v, got %s", CodeDirty, dirty, ErrorCode(dirty))
+	}
+	exists := fmt.Errorf("wrapped: %w", git.ErrBranchExists)
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{dirty, 8},
+		{withCode(CodeGit, git.ErrLocked), 9},
+		{exists, 10},
+		{withCode(CodeGit, git.ErrRefNotFound), 2},
+		{fmt.Errorf("plain"), 1},
+		{errorf(CodeInvalidInput, "bad"), 2},
+		{errorf(CodeAmbiguous, "several"), 4},
+		{errorf(CodeCancelled, "cancelled"), 6},
+		{errorf(CodeGit, "failed"), 14},
+		{notARepo, 11},
+	}
+	seen := map[int]string{}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+	for _, c := range errorCodes {
+		if other, ok := seen[c.exit]; ok || c.exit <= 1 {
+			t.Errorf("exit code %d of %s is reserved or shared with %s", c.exit, c.code, other)
+		}
+		seen[c.exit] = c.code
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		age      time.Duration
+		expected string
+	}{
+		{10 * time.Second, "just now"},
+		{5 * time.Minute, "5m ago"},
+		{3 * time.Hour, "3h ago"},
+		{50 * time.Hour, "2d ago"},
+		{90 * 24 * time.Hour, "3mo ago"},
+		{800 * 24 * time.Hour, "2y ago"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAge(tt.age); got != tt.expected {
+			t.Errorf("formatAge(%v) = %q, expected %q", tt.age, got, tt.expected)
+		}
+	}
+}
+
+func TestList_SkipsStaleDirectories(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	createMockWorktree(t, tmpDir, "repo-stale")
+	createTestWorktree(t, createTestRepo(t), tmpDir, "repo-feature", "feature")
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := List(ListOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if strings.Contains(output, "repo-stale") {
+			t.Errorf("expected stale directory to be skipped, got: %s", output)
+		}
+		if !strings.Contains(output, "repo-feature") {
+			t.Errorf("expected 'repo-feature' in output, got: %s", output)
+		}
+	})
+}
+
+func TestList_IncludesWorktreesOutsideWTHome(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, tmpDir, "repo-feature", "feature")
+	elsewhere := createTestWorktree(t, repo, t.TempDir(), "manual", "manual")
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := List(ListOptions{Short: true})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if !strings.Contains(output, elsewhere) {
+			t.Errorf("expected worktree outside WT_HOME in output, got: %s", output)
+		}
+	})
+}
+
+func TestList_NestedTemplate(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	createTestWorktree(t, createTestRepo(t), tmpDir, filepath.Join("repo", "feature"), "feature")
+	createTestWorktree(t, createTestRepo(t), tmpDir, "repo-flat", "flat")
+	t.Setenv("WT_PATH_TEMPLATE", "{wt_home}/{repo}/{name}")
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := List(ListOptions{Short: true})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if !strings.Contains(output, filepath.Join(tmpDir, "repo", "feature")) {
+			t.Errorf("expected nested worktree in output, got: %s", output)
+		}
+		if strings.Contains(output, "repo-flat") {
+			t.Errorf("expected worktree outside the layout to be skipped, got: %s", output)
+		}
+	})
+}
+
+func TestList_LegacyDirectory(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	// A worktree without a dash doesn't fit {repo}-{name}, but WT_HOME
+	// was always searched as a whole
+	legacy := createTestWorktree(t, createTestRepo(t), wtHome, "legacy", "legacy")
+
+	output := captureOutput(func() {
+		if err := List(ListOptions{Short: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, legacy) {
+		t.Errorf("expected the dash-less worktree in output, got: %s", output)
+	}
+}
+
 func TestNavigate_SingleMatch(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "wt-test-*")
 	if err != nil {
@@ -103,12 +474,13 @@ func TestNavigate_SingleMatch(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	createMockWorktree(t, tmpDir, "repo-feature")
-	createMockWorktree(t, tmpDir, "repo-bugfix")
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, tmpDir, "repo-feature", "feature")
+	createTestWorktree(t, repo, tmpDir, "repo-bugfix", "bugfix")
 
 	withWTHome(t, tmpDir, func() {
 		output := captureOutput(func() {
-			err := Navigate("feature")
+			err := Navigate("feature", NavigateOptions{})
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -120,6 +492,30 @@ func TestNavigate_SingleMatch(t *testing.T) {
 	})
 }
 
+func TestNavigate_MatchesBranch(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	repo := createTestRepo(t)
+	loginPath := createTestWorktree(t, repo, tmpDir, "repo-login", "feature/JIRA-123")
+	createTestWorktree(t, repo, tmpDir, "repo-bugfix", "bugfix")
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			err := Navigate("JIRA", NavigateOptions{})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if !strings.Contains(output, "WT_CD_PATH="+loginPath) {
+			t.Errorf("expected WT_CD_PATH=%s, got: %s", loginPath, output)
+		}
+	})
+}
+
 func TestNavigate_NoMatch(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "wt-test-*")
 	if err != nil {
@@ -127,10 +523,10 @@ func TestNavigate_NoMatch(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	createMockWorktree(t, tmpDir, "repo-feature")
+	createTestWorktree(t, createTestRepo(t), tmpDir, "repo-feature", "feature")
 
 	withWTHome(t, tmpDir, func() {
-		err := Navigate("nonexistent")
+		err := Navigate("nonexistent", NavigateOptions{})
 		if err == nil {
 			t.Error("expected error for non-matching pattern")
 		}
@@ -148,7 +544,7 @@ func TestNavigate_EmptyWorktrees(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	withWTHome(t, tmpDir, func() {
-		err := Navigate("something")
+		err := Navigate("something", NavigateOptions{})
 		if err == nil {
 			t.Error("expected error for empty worktrees")
 		}
@@ -173,7 +569,7 @@ func TestCreate_NotInGitRepo(t *testing.T) {
 	defer func() { _ = os.Chdir(originalDir) }()
 
 	withWTHome(t, tmpDir, func() {
-		err := Create("test-worktree")
+		err := Create("test-worktree", CreateOptions{})
 		if err == nil {
 			t.Error("expected error when not in git repo")
 		}
@@ -183,6 +579,83 @@ func TestCreate_NotInGitRepo(t *testing.T) {
 	})
 }
 
+func TestCreate_InvalidBaseRef(t *testing.T) {
+	// This test assumes we're running in a git repository
+	if _, err := git.GetRepoRoot(); err != nil {
+		t.Skipf("skipping test, not in a git repository: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	withWTHome(t, tmpDir, func() {
+		err := Create("test-worktree", CreateOptions{From: "refs/heads/does-not-exist-xyz"})
+		if err == nil {
+			t.Fatal("expected error for non-existent base ref")
+		}
+		if !strings.Contains(err.Error(), "does not exist") {
+			t.Errorf("expected 'does not exist' error, got: %v", err)
+		}
+	})
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"feature-x", "feature-x"},
+		{"feature/JIRA-123-login", "feature-JIRA-123-login"},
+		{"fix: login bug", "fix-login-bug"},
+		{"/leading/and/trailing/", "leading-and-trailing"},
+		{"v1.2_rc", "v1.2_rc"},
+		{"a//b", "a-b"},
+		{"../", ""},
+		{"café", "café"},
+		{"cafe\u0301/crème", "cafe\u0301-crème"},
+		{"日本", "日本"},
+		{"feature/日本語 ログイン", "feature-日本語-ログイン"},
+		{"tab\there\x00", "tab-here"},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.name); got != tt.expected {
+			t.Errorf("slugify(%q) = %q, expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestShowConfig(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "wt-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	userConfig := filepath.Join(tmpDir, "config.toml")
+	if err := os.WriteFile(userConfig, []byte("base_ref = \"origin/main\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	t.Setenv("WT_CONFIG", userConfig)
+
+	withWTHome(t, tmpDir, func() {
+		output := captureOutput(func() {
+			if err := ShowConfig(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if !strings.Contains(output, "(env WT_HOME)") {
+			t.Errorf("expected wt_home to come from env, got: %s", output)
+		}
+		if !strings.Contains(output, `"origin/main"`) || !strings.Contains(output, "("+userConfig+")") {
+			t.Errorf("expected base_ref from %s, got: %s", userConfig, output)
+		}
+	})
+}
+
 func TestDelete_NoMatch(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "wt-test-*")
 	if err != nil {
@@ -190,10 +663,10 @@ func TestDelete_NoMatch(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	createMockWorktree(t, tmpDir, "repo-feature")
+	createTestWorktree(t, createTestRepo(t), tmpDir, "repo-feature", "feature")
 
 	withWTHome(t, tmpDir, func() {
-		err := Delete("nonexistent")
+		err := Delete([]string{"nonexistent"}, DeleteOptions{})
 		if err == nil {
 			t.Error("expected error for non-matching pattern")
 		}
@@ -211,7 +684,7 @@ func TestDelete_EmptyWorktrees(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	withWTHome(t, tmpDir, func() {
-		err := Delete("something")
+		err := Delete([]string{"something"}, DeleteOptions{})
 		if err == nil {
 			t.Error("expected error for empty worktrees")
 		}
@@ -220,3 +693,951 @@ func TestDelete_EmptyWorktrees(t *testing.T) {
 		}
 	})
 }
+
+func TestWorktreePreview(t *testing.T) {
+	repo := createTestRepo(t)
+	wtPath := createTestWorktree(t, repo, t.TempDir(), "repo-feature", "feature")
+	if err := os.WriteFile(filepath.Join(wtPath, "new.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	preview := worktreePreview(git.Worktree{Name: "repo-feature", Path: wtPath, Branch: "feature"})
+	for _, want := range []string{"Branch:  feature", "Status:  1 untracked", "Recent commits:", "initial"} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("expected preview to contain %q, got:\n%s", want, preview)
+		}
+	}
+}
+
+func TestHighlightLabel(t *testing.T) {
+	wt := git.Worktree{Name: "repo-login", Branch: "feature/login"}
+
+	if got, want := highlightLabel(fuzzy.ParseQuery("rl"), wt), "\x1b[1;32mr\x1b[0mepo-\x1b[1;32ml\x1b[0mogin [feature/login]"; got != want {
+		t.Errorf("highlightLabel = %q, want %q", got, want)
+	}
+	// The branch matches "feat" better than the name does
+	if got, want := highlightLabel(fuzzy.ParseQuery("feat"), wt), "repo-login [\x1b[1;32mf\x1b[0m\x1b[1;32me\x1b[0m\x1b[1;32ma\x1b[0m\x1b[1;32mt\x1b[0mure/login]"; got != want {
+		t.Errorf("highlightLabel = %q, want %q", got, want)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("NO_COLOR", "")
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		color, noColor string
+		want           bool
+	}{
+		{"always", "1", true},
+		{"never", "", false},
+		{"auto", "", false}, // not a terminal
+	}
+	for _, tt := range tests {
+		t.Setenv("WT_COLOR", tt.color)
+		t.Setenv("NO_COLOR", tt.noColor)
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if got := useColor(cfg, f); got != tt.want {
+			t.Errorf("useColor with color=%s NO_COLOR=%q = %v, want %v", tt.color, tt.noColor, got, tt.want)
+		}
+	}
+}
+
+func TestMatchWorktrees_Query(t *testing.T) {
+	worktrees := []git.Worktree{
+		{Name: "api-old", Branch: "legacy"},
+		{Name: "api", Branch: "main"},
+		{Name: "web", Branch: "feature/api-client"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"api", []string{"api", "api-old", "web"}},
+		{"api !old", []string{"api", "web"}},
+		{"^api !legacy", []string{"api"}},
+		{"web client", []string{"web"}},
+		{"!feature", []string{"api", "api-old"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, wt := range matchWorktrees(fuzzy.ParseQuery(tt.query), worktrees) {
+			got = append(got, wt.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("matchWorktrees(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQuery_IgnoreAccents(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	worktrees := []git.Worktree{{Name: "café", Branch: "café"}}
+
+	for _, tt := range []struct {
+		setting string
+		want    int
+	}{{"true", 1}, {"false", 0}} {
+		t.Setenv("WT_IGNORE_ACCENTS", tt.setting)
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("failed to load config: %v", err)
+		}
+		if got := len(matchWorktrees(parseQuery(cfg, "cafe"), worktrees)); got != tt.want {
+			t.Errorf("with ignore_accents=%s got %d matches, want %d", tt.setting, got, tt.want)
+		}
+	}
+}
+
+func TestNavigate_Frecency(t *testing.T) {
+	t.Setenv("WT_DATA_DIR", t.TempDir())
+	wtHome := t.TempDir()
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, wtHome, "repo-api-server", "api-server")
+	favorite := createTestWorktree(t, repo, wtHome, "repo-api-client", "api-client")
+	createTestWorktree(t, repo, wtHome, "repo-api-docs", "api-docs")
+
+	hist, err := history.Load(os.Getenv("WT_DATA_DIR"))
+	if err != nil {
+		t.Fatalf("failed to load history: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		hist.Visit("repo-api-client", favorite, time.Now())
+	}
+	if err := hist.Save(time.Now()); err != nil {
+		t.Fatalf("failed to save history: %v", err)
+	}
+
+	withWTHome(t, wtHome, func() {
+		// The favorite dominates, so no prompt is shown
+		output := captureOutput(func() {
+			if err := Navigate("api", NavigateOptions{}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if !strings.Contains(output, "WT_CD_PATH="+favorite) {
+			t.Errorf("expected the most visited worktree, got: %s", output)
+		}
+	})
+
+	hist, err = history.Load(os.Getenv("WT_DATA_DIR"))
+	if err != nil {
+		t.Fatalf("failed to load history: %v", err)
+	}
+	if got := hist.Entries(); len(got) != 1 || got[0].Count != 4 {
+		t.Errorf("expected the visit to be recorded, got %+v", got)
+	}
+}
+
+func TestRankByFrecency(t *testing.T) {
+	hist, err := history.Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to load history: %v", err)
+	}
+	now := time.Now()
+	matches := []scoredWorktree{
+		{wt: git.Worktree{Name: "a", Path: "/a"}, match: fuzzy.Match{Text: "a", Score: 50}},
+		{wt: git.Worktree{Name: "b", Path: "/b"}, match: fuzzy.Match{Text: "b", Score: 40}},
+	}
+
+	hist.Visit("b", "/b", now)
+	if rankByFrecency(matches, hist, now) {
+		t.Error("expected a single visit not to dominate")
+	}
+	if matches[0].wt.Name != "a" {
+		t.Errorf("expected the better string match to stay first, got %s", matches[0].wt.Name)
+	}
+
+	for i := 0; i < 5; i++ {
+		hist.Visit("b", "/b", now)
+	}
+	if !rankByFrecency(matches, hist, now) {
+		t.Error("expected frequent visits to dominate")
+	}
+	if matches[0].wt.Name != "b" {
+		t.Errorf("expected the frequently visited worktree first, got %s", matches[0].wt.Name)
+	}
+
+	hist.Visit("a", "/a", now)
+	hist.Visit("a", "/a", now)
+	if rankByFrecency(matches, hist, now) {
+		t.Error("expected no dominance when both are visited")
+	}
+}
+
+func TestForget(t *testing.T) {
+	t.Setenv("WT_DATA_DIR", t.TempDir())
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	hist, _ := history.Load(os.Getenv("WT_DATA_DIR"))
+	hist.Visit("repo-old", "/trees/repo-old", time.Now())
+	hist.Visit("repo-new", "/trees/repo-new", time.Now())
+	if err := hist.Save(time.Now()); err != nil {
+		t.Fatalf("failed to save history: %v", err)
+	}
+
+	output := captureOutput(func() {
+		if err := Forget("repo-old", ForgetOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Forgot repo-old") {
+		t.Errorf("expected confirmation, got: %s", output)
+	}
+
+	hist, _ = history.Load(os.Getenv("WT_DATA_DIR"))
+	if got := hist.Entries(); len(got) != 1 || got[0].Name != "repo-new" {
+		t.Errorf("expected only repo-new to remain, got %+v", got)
+	}
+
+	if err := Forget("repo-old", ForgetOptions{}); ErrorCode(err) != CodeNoMatch {
+		t.Errorf("expected %s for an unknown name, got %v", CodeNoMatch, err)
+	}
+}
+
+func TestBack(t *testing.T) {
+	t.Setenv("WT_DATA_DIR", t.TempDir())
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+
+	if err := Back(HistoryOptions{}); ErrorCode(err) != CodeNoMatch {
+		t.Errorf("expected %s with an empty history, got %v", CodeNoMatch, err)
+	}
+
+	repo := createTestRepo(t)
+	older := createTestWorktree(t, repo, t.TempDir(), "repo-older", "older")
+	newer := createTestWorktree(t, repo, t.TempDir(), "repo-newer", "newer")
+	gone := filepath.Join(t.TempDir(), "repo-gone")
+
+	hist, _ := history.Load(os.Getenv("WT_DATA_DIR"))
+	now := time.Now()
+	hist.Visit("repo-older", older, now.Add(-2*time.Minute))
+	hist.Visit("repo-newer", newer, now.Add(-time.Minute))
+	hist.Visit("repo-gone", gone, now)
+	if err := hist.Save(now); err != nil {
+		t.Fatalf("failed to save history: %v", err)
+	}
+
+	originalDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(originalDir) }()
+
+	// From inside the newer worktree, the previous one is the older one;
+	// the deleted directory is skipped
+	if err := os.Chdir(newer); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	output := captureOutput(func() {
+		if err := Back(HistoryOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "WT_CD_PATH="+older) {
+		t.Errorf("expected to go back to %s, got: %s", older, output)
+	}
+
+	// Going back records a visit, so the next jump returns
+	if err := os.Chdir(older); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+	output = captureOutput(func() {
+		if err := Back(HistoryOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "WT_CD_PATH="+newer) {
+		t.Errorf("expected to go back to %s, got: %s", newer, output)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Setenv("WT_DATA_DIR", t.TempDir())
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+
+	first, second := t.TempDir(), t.TempDir()
+	hist, _ := history.Load(os.Getenv("WT_DATA_DIR"))
+	hist.Visit("first", first, time.Now().Add(-time.Hour))
+	hist.Visit("second", second, time.Now())
+	if err := hist.Save(time.Now()); err != nil {
+		t.Fatalf("failed to save history: %v", err)
+	}
+
+	output := captureOutput(func() {
+		if err := History(0, HistoryOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "1  second") || !strings.HasPrefix(lines[1], "2  first") {
+		t.Errorf("expected second then first, got:\n%s", output)
+	}
+
+	output = captureOutput(func() {
+		if err := History(2, HistoryOptions{Format: FormatPorcelain}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "worktree "+first) {
+		t.Errorf("expected entry 2 to be %s, got: %s", first, output)
+	}
+
+	if err := History(3, HistoryOptions{}); ErrorCode(err) != CodeInvalidInput {
+		t.Errorf("expected %s for an out of range entry, got %v", CodeInvalidInput, err)
+	}
+}
+
+func TestNavigate_PreserveSubdir(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	feature := createTestWorktree(t, repo, wtHome, "repo-feature", "feature")
+	bugfix := createTestWorktree(t, repo, wtHome, "repo-bugfix", "bugfix")
+	handlers := filepath.Join(feature, "services", "api", "handlers")
+	for _, dir := range []string{handlers, filepath.Join(bugfix, "services", "api")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	originalDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(originalDir) }()
+	if err := os.Chdir(handlers); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		env  string
+		opts NavigateOptions
+		want string
+	}{
+		// services/api/handlers doesn't exist in bugfix, so its nearest
+		// existing ancestor is used
+		{"default", "", NavigateOptions{}, filepath.Join(bugfix, "services", "api")},
+		{"root flag", "", NavigateOptions{Root: true}, bugfix},
+		{"disabled", "false", NavigateOptions{}, bugfix},
+		{"subdir flag", "false", NavigateOptions{Subdir: true}, filepath.Join(bugfix, "services", "api")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WT_PRESERVE_SUBDIR", tt.env)
+			output := captureOutput(func() {
+				if err := Navigate("bugfix", tt.opts); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			})
+			if !strings.Contains(output, "WT_CD_PATH="+tt.want+"\n") {
+				t.Errorf("expected WT_CD_PATH=%s, got: %s", tt.want, output)
+			}
+		})
+	}
+
+	err := Navigate("bugfix", NavigateOptions{Root: true, Subdir: true})
+	if ErrorCode(err) != CodeInvalidInput {
+		t.Errorf("expected %s for --root with --subdir, got %v", CodeInvalidInput, err)
+	}
+}
+
+func TestEmitCDPath_File(t *testing.T) {
+	cdFile := filepath.Join(t.TempDir(), "cd")
+	t.Setenv(shell.CDFileEnv, cdFile)
+
+	path := "/tmp/repo a=b/feature"
+	output := captureOutput(func() {
+		if err := emitCDPath(path); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if output != "" {
+		t.Errorf("expected nothing on stdout with %s set, got: %q", shell.CDFileEnv, output)
+	}
+	data, err := os.ReadFile(cdFile)
+	if err != nil {
+		t.Fatalf("failed to read cd file: %v", err)
+	}
+	if string(data) != path {
+		t.Errorf("cd file contains %q, want %q", data, path)
+	}
+}
+
+func TestCompleteWords(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, wtHome, "repo-feature", "feature")
+
+	colorFlag := CompletionFlag{Name: "color", Usage: "Color", TakesValue: true}
+	opts := CompleteOptions{
+		Commands: []CompletionCommand{
+			{Name: "create", Summary: "Create", Flags: []CompletionFlag{{Name: "from", TakesValue: true}}},
+			{Name: "go", Summary: "Go", Flags: []CompletionFlag{colorFlag, {Name: "root"}}},
+			{Name: "rm", Aliases: []string{"delete"}, Summary: "Delete", Flags: []CompletionFlag{colorFlag}},
+			{Name: "config", Summary: "Config"},
+			{Name: "completion", Summary: "Completion"},
+		},
+		Flags: []CompletionFlag{
+			{Name: "d", Usage: "Delete", TakesValue: true, Command: "rm"},
+			colorFlag,
+			{Name: "root", Usage: "Root"},
+		},
+	}
+	all := []string{"main-repo", "repo-feature", "create", "go", "rm", "config", "completion"}
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{""}, all},
+		{[]string{"-"}, []string{"-d", "--color", "--root"}},
+		{[]string{"--color", ""}, []string{"auto", "always", "never"}},
+		{[]string{"--root", ""}, all},
+		{[]string{"--color", "never", "feat", ""}, []string{"main-repo", "repo-feature"}},
+		{[]string{"-d", ""}, []string{"main-repo", "repo-feature"}},
+		{[]string{"-d", "x", "-"}, []string{"--color"}},
+		{[]string{"delete", "--"}, []string{"--color"}},
+		{[]string{"create", "--from", "x", "-"}, []string{"--from"}},
+		{[]string{"completion", ""}, []string{"bash", "fish", "zsh"}},
+		{[]string{"completion", "bash", ""}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, c := range completeWords(tt.words, opts) {
+			got = append(got, c.value)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("completeWords(%q) = %v, want %v", tt.words, got, tt.want)
+		}
+	}
+
+	// Config keys are described
+	keys := completeWords([]string{"config", ""}, opts)
+	if len(keys) != len(config.Keys()) || keys[0].desc == "" {
+		t.Errorf("expected described config keys, got %v", keys)
+	}
+}
+
+func TestDelete_UnsavedWork(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_CONFIRM_DELETE", "false")
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	clean := createTestWorktree(t, repo, wtHome, "repo-clean", "clean")
+	dirty := createTestWorktree(t, repo, wtHome, "repo-dirty", "dirty")
+	if err := os.WriteFile(filepath.Join(dirty, "notes.txt"), []byte("todo\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	captureOutput(func() {
+		if err := Delete([]string{"clean"}, DeleteOptions{}); err != nil {
+			t.Errorf("unexpected error deleting a clean worktree: %v", err)
+		}
+	})
+	if _, err := os.Stat(clean); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", clean)
+	}
+
+	err := Delete([]string{"dirty"}, DeleteOptions{})
+	if ErrorCode(err) != CodeDirty || !strings.Contains(err.Error(), "1 untracked file") {
+		t.Errorf("expected %s error naming the untracked file, got %v", CodeDirty, err)
+	}
+	if _, err := os.Stat(filepath.Join(dirty, "notes.txt")); err != nil {
+		t.Errorf("expected the dirty worktree to be kept: %v", err)
+	}
+
+	captureOutput(func() {
+		if err := Delete([]string{"dirty"}, DeleteOptions{Force: true}); err != nil {
+			t.Errorf("unexpected error with Force: %v", err)
+		}
+	})
+	if _, err := os.Stat(dirty); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed with Force", dirty)
+	}
+}
+
+func TestDelete_Cancelled(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	wtPath := createTestWorktree(t, repo, wtHome, "repo-kept", "kept")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	old := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = old }()
+	w.WriteString("n\n")
+	w.Close()
+
+	if err := Delete([]string{"kept"}, DeleteOptions{}); !errors.Is(err, ErrCancelled) {
+		t.Errorf("expected ErrCancelled, got %v", err)
+	}
+	if _, err := os.Stat(wtPath); err != nil {
+		t.Errorf("expected %s to be kept: %v", wtPath, err)
+	}
+}
+
+func TestDelete_Prunable(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_CONFIRM_DELETE", "false")
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	// A live worktree lets wt find the repository
+	createTestWorktree(t, repo, wtHome, "repo-live", "live")
+	for _, name := range []string{"one", "two"} {
+		path := createTestWorktree(t, repo, wtHome, "repo-"+name, name)
+		if err := os.RemoveAll(path); err != nil {
+			t.Fatalf("failed to remove %s: %v", path, err)
+		}
+	}
+
+	captureOutput(func() {
+		if err := Delete([]string{"repo-one"}, DeleteOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	// Only the selected missing worktree loses its metadata
+	worktrees, err := git.ListRepoWorktrees(repo)
+	if err != nil {
+		t.Fatalf("failed to list worktrees: %v", err)
+	}
+	var names []string
+	for _, wt := range worktrees {
+		if wt.Prunable {
+			names = append(names, filepath.Base(wt.Path))
+		}
+	}
+	if len(names) != 1 || names[0] != "repo-two" {
+		t.Errorf("expected only repo-two to be left, got %v", names)
+	}
+}
+
+func TestDelete_DeleteBranch(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_CONFIRM_DELETE", "false")
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	createTestWorktree(t, repo, wtHome, "repo-done", "done")
+	unmerged := createTestWorktree(t, repo, wtHome, "repo-unmerged", "unmerged")
+	createTestWorktree(t, repo, wtHome, "repo-forced", "forced")
+	createTestWorktree(t, repo, wtHome, "repo-kept", "kept")
+	// Commits on a remote are safe to delete the worktree over, but still
+	// unmerged into the base
+	runGit(t, unmerged, "-c", "user.name=wt", "-c", "user.email=wt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "local work")
+	runGit(t, repo, "update-ref", "refs/remotes/origin/unmerged", "unmerged")
+	runGit(t, repo, "update-ref", "refs/heads/forced", "unmerged")
+	runGit(t, repo, "update-ref", "refs/remotes/origin/forced", "unmerged")
+
+	branchExists := func(branch string) bool {
+		return exec.Command("git", "-C", repo, "rev-parse", "--verify", "-q", "refs/heads/"+branch).Run() == nil
+	}
+
+	output := captureOutput(func() {
+		if err := Delete([]string{"done"}, DeleteOptions{DeleteBranch: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if branchExists("done") || !strings.Contains(output, "Deleted branch: done") {
+		t.Errorf("expected the merged branch to be deleted, got output %q", output)
+	}
+
+	captureOutput(func() {
+		if err := Delete([]string{"unmerged"}, DeleteOptions{DeleteBranch: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !branchExists("unmerged") {
+		t.Error("expected the unmerged branch to be kept")
+	}
+
+	captureOutput(func() {
+		if err := Delete([]string{"forced"}, DeleteOptions{ForceDeleteBranch: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if branchExists("forced") {
+		t.Error("expected the unmerged branch to be deleted with ForceDeleteBranch")
+	}
+
+	// Without the flag or setting, branches stay
+	captureOutput(func() {
+		if err := Delete([]string{"kept"}, DeleteOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !branchExists("kept") {
+		t.Error("expected the branch to be kept by default")
+	}
+}
+
+func TestDelete_Several(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_CONFIRM_DELETE", "false")
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	other := createTestRepo(t)
+	api := createTestWorktree(t, repo, wtHome, "feat-api", "api")
+	ui := createTestWorktree(t, repo, wtHome, "feat-ui", "ui")
+	cli := createTestWorktree(t, other, wtHome, "feat-cli", "cli")
+	docs := createTestWorktree(t, repo, wtHome, "repo-docs", "docs")
+
+	// Nothing is deleted unless every query matches
+	err := Delete([]string{"docs", "nonexistent"}, DeleteOptions{})
+	if ErrorCode(err) != CodeNoMatch {
+		t.Errorf("expected %s error, got %v", CodeNoMatch, err)
+	}
+	if _, err := os.Stat(docs); err != nil {
+		t.Errorf("expected docs to be kept: %v", err)
+	}
+
+	output := captureOutput(func() {
+		if err := Delete([]string{"feat"}, DeleteOptions{Format: FormatJSON, AllMatching: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	var result struct {
+		Worktrees []struct {
+			Worktree struct {
+				Name string `json:"name"`
+			} `json:"worktree"`
+			Deleted bool `json:"deleted"`
+		} `json:"worktrees"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", output, err)
+	}
+	if len(result.Worktrees) != 3 {
+		t.Fatalf("expected 3 results, got %+v", result.Worktrees)
+	}
+	for _, r := range result.Worktrees {
+		if !r.Deleted {
+			t.Errorf("expected %s to be deleted", r.Worktree.Name)
+		}
+	}
+	for _, path := range []string{api, ui, cli} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+
+	// A worktree named twice is deleted once
+	output = captureOutput(func() {
+		if err := Delete([]string{"docs", "repo-docs"}, DeleteOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if strings.Count(output, "Deleted worktree: repo-docs") != 1 {
+		t.Errorf("expected docs to be deleted once, got %q", output)
+	}
+}
+
+func TestDelete_NegatedPattern(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_CONFIRM_DELETE", "false")
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	api := createTestWorktree(t, repo, wtHome, "repo-api", "api")
+	old := createTestWorktree(t, repo, wtHome, "repo-api-old", "api-old")
+	feature := createTestWorktree(t, repo, wtHome, "repo-feature", "feature")
+
+	// "!old" narrows "api" rather than matching every other worktree
+	captureOutput(func() {
+		if err := Delete([]string{"api", "!old"}, DeleteOptions{AllMatching: true, Yes: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if _, err := os.Stat(api); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", api)
+	}
+	for _, path := range []string{old, feature} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestNonInteractive(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_NONINTERACTIVE", "true")
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	api := createTestWorktree(t, repo, wtHome, "repo-api-v1", "v1")
+	createTestWorktree(t, repo, wtHome, "repo-api-v2", "v2")
+
+	// Ambiguous queries fail with the candidates instead of prompting
+	err := Navigate("api-v", NavigateOptions{})
+	var coded *Error
+	if ErrorCode(err) != CodeAmbiguous || !errors.As(err, &coded) || len(coded.Candidates) != 2 {
+		t.Fatalf("expected %s error with 2 candidates, got %v", CodeAmbiguous, err)
+	}
+	output := captureOutput(func() { ReportError(FormatJSON, err) })
+	if !strings.Contains(output, `"candidates"`) || !strings.Contains(output, api) {
+		t.Errorf("expected the candidates in the JSON error, got %q", output)
+	}
+	if err := Delete([]string{"api-v"}, DeleteOptions{Yes: true}); ErrorCode(err) != CodeAmbiguous {
+		t.Errorf("expected %s error deleting, got %v", CodeAmbiguous, err)
+	}
+
+	// Deleting needs --yes
+	err = Delete([]string{"repo-api-v1"}, DeleteOptions{})
+	if ErrorCode(err) != CodeNeedsYes {
+		t.Errorf("expected %s error, got %v", CodeNeedsYes, err)
+	}
+	if _, err := os.Stat(api); err != nil {
+		t.Errorf("expected %s to be kept: %v", api, err)
+	}
+	captureOutput(func() {
+		if err := Delete([]string{"repo-api-v1"}, DeleteOptions{Yes: true}); err != nil {
+			t.Errorf("unexpected error with Yes: %v", err)
+		}
+	})
+	if _, err := os.Stat(api); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed with Yes", api)
+	}
+}
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"2", []int{1}},
+		{"1,3-5", []int{0, 2, 3, 4}},
+		{"4 2, 2", []int{3, 1}},
+		{"5-5", []int{4}},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, 5)
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("parseSelection(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "0", "6", "3-1", "1-", "a"} {
+		if got, err := parseSelection(input, 5); err == nil {
+			t.Errorf("parseSelection(%q) = %v, expected an error", input, got)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	commit := []string{"-c", "user.name=wt", "-c", "user.email=wt@example.com", "commit", "-q", "--allow-empty"}
+	repo := createTestRepo(t)
+	fresh := createTestWorktree(t, repo, wtHome, "repo-fresh", "fresh")
+	done := createTestWorktree(t, repo, wtHome, "repo-done", "done")
+	dirty := createTestWorktree(t, repo, wtHome, "repo-dirty", "dirty")
+	gone := createTestWorktree(t, repo, wtHome, "repo-gone", "gone")
+	if err := os.RemoveAll(gone); err != nil {
+		t.Fatalf("failed to remove worktree directory: %v", err)
+	}
+	runGit(t, done, append(commit, "-m", "done")...)
+	runGit(t, dirty, append(commit, "-m", "dirty")...)
+	runGit(t, repo, "merge", "-q", "--ff-only", "done")
+	runGit(t, repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com", "merge", "-q", "--no-edit", "dirty")
+	if err := os.WriteFile(filepath.Join(dirty, "notes.txt"), []byte("todo\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	output := captureOutput(func() {
+		if err := Prune(PruneOptions{DryRun: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "repo-done") || !strings.Contains(output, "Dry run") {
+		t.Errorf("expected the dry run to list repo-done, got %q", output)
+	}
+	if _, err := os.Stat(done); err != nil {
+		t.Errorf("expected the dry run to keep %s", done)
+	}
+
+	output = captureOutput(func() {
+		if err := Prune(PruneOptions{Yes: true, DeleteBranch: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if _, err := os.Stat(done); !os.IsNotExist(err) {
+		t.Errorf("expected the merged worktree to be pruned, got output %q", output)
+	}
+	if exec.Command("git", "-C", repo, "rev-parse", "--verify", "-q", "refs/heads/done").Run() == nil {
+		t.Error("expected the merged branch to be deleted")
+	}
+	// git forgets the worktree whose directory was missing
+	list, err := exec.Command("git", "-C", repo, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		t.Fatalf("git worktree list failed: %v", err)
+	}
+	if strings.Contains(string(list), "worktree "+gone+"\n") {
+		t.Errorf("expected %s to be pruned from git's metadata, got %q", gone, list)
+	}
+	// A new branch is not done with, and unsaved work is kept as by rm
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("expected the fresh worktree to be kept: %v", err)
+	}
+	if _, err := os.Stat(dirty); err != nil || !strings.Contains(output, "1 untracked file") {
+		t.Errorf("expected the dirty worktree to be kept and reported, got output %q", output)
+	}
+
+	// Much later, the fresh worktree is stale
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	worktrees, err := listWorktrees(cfg)
+	if err != nil {
+		t.Fatalf("failed to list worktrees: %v", err)
+	}
+	candidates := findPruneCandidates(cfg, worktrees, 30, time.Now().Add(60*24*time.Hour))
+	found := false
+	for _, c := range candidates {
+		if c.wt.Path == fresh {
+			found = strings.HasPrefix(strings.Join(c.reasons, ", "), "untouched for 60 days")
+		}
+	}
+	if !found {
+		t.Errorf("expected the fresh worktree to be stale, got %+v", candidates)
+	}
+}
+
+func TestDelete_Undo(t *testing.T) {
+	t.Setenv("WT_CONFIG", filepath.Join(t.TempDir(), "config.toml"))
+	t.Setenv("WT_CONFIRM_DELETE", "false")
+	t.Setenv("WT_DATA_DIR", t.TempDir())
+	wtHome := t.TempDir()
+	t.Setenv("WT_HOME", wtHome)
+
+	repo := createTestRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, repo, "add", "file.txt")
+	runGit(t, repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com", "commit", "-q", "-m", "file")
+	wtPath := createTestWorktree(t, repo, wtHome, "repo-feature", "feature")
+	runGit(t, wtPath, "-c", "user.name=wt", "-c", "user.email=wt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "local work")
+	if err := os.WriteFile(filepath.Join(wtPath, "file.txt"), []byte("two\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, "notes.txt"), []byte("todo\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	head, err := git.ResolveCommit(wtPath, "HEAD")
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %v", err)
+	}
+
+	captureOutput(func() {
+		if err := Delete([]string{"feature"}, DeleteOptions{Force: true, ForceDeleteBranch: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if _, err := os.Stat(wtPath); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed", wtPath)
+	}
+
+	output := captureOutput(func() {
+		if err := TrashList(TrashOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if !strings.Contains(output, "repo-feature") || !strings.Contains(output, "changes, 1 untracked file") {
+		t.Errorf("expected the trash to list repo-feature, got %q", output)
+	}
+
+	captureOutput(func() {
+		if err := Undo("", TrashOptions{}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if data, err := os.ReadFile(filepath.Join(wtPath, "file.txt")); err != nil || string(data) != "two\n" {
+		t.Errorf("expected the change to file.txt to be restored, got %q, %v", data, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(wtPath, "notes.txt")); err != nil || string(data) != "todo\n" {
+		t.Errorf("expected notes.txt to be restored, got %q, %v", data, err)
+	}
+	if branch, err := git.HeadBranch(wtPath); err != nil || branch != "feature" {
+		t.Errorf("expected the deleted branch to be recreated, got %q, %v", branch, err)
+	}
+	if got, _ := git.ResolveCommit(wtPath, "HEAD"); got != head {
+		t.Errorf("expected HEAD %s, got %s", head, got)
+	}
+	if refs, _ := exec.Command("git", "-C", repo, "for-each-ref", "refs/wt-trash/").Output(); len(refs) != 0 {
+		t.Errorf("expected the trash ref to be deleted, got %s", refs)
+	}
+	if err := Undo("", TrashOptions{}); ErrorCode(err) != CodeNoMatch {
+		t.Errorf("expected %s from an empty trash, got %v", CodeNoMatch, err)
+	}
+
+	// Without a trash, nothing is kept
+	t.Setenv("WT_TRASH_DAYS", "0")
+	captureOutput(func() {
+		if err := Delete([]string{"feature"}, DeleteOptions{Force: true}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+	if err := Undo("repo-feature", TrashOptions{}); ErrorCode(err) != CodeNoMatch {
+		t.Errorf("expected %s with trash_days = 0, got %v", CodeNoMatch, err)
+	}
+}
+
+func TestBranchPlanDescribe(t *testing.T) {
+	tests := []struct {
+		plan branchPlan
+		want string
+	}{
+		{branchPlan{branch: "b"}, ""},
+		{branchPlan{branch: "b", delete: true, reason: "merged into main"}, " and branch 'b' (merged into main)"},
+		{branchPlan{branch: "b", delete: true, force: true}, " and force-delete branch 'b'"},
+		{branchPlan{branch: "b", reason: "not merged into main"}, " but keep branch 'b' (not merged into main; -D deletes it anyway)"},
+	}
+	for _, tt := range tests {
+		if got := tt.plan.describe(); got != tt.want {
+			t.Errorf("describe(%+v) = %q, want %q", tt.plan, got, tt.want)
+		}
+	}
+}
+
+func TestDescribeUnsavedWork(t *testing.T) {
+	tests := []struct {
+		work git.UnsavedWork
+		want string
+	}{
+		{git.UnsavedWork{Untracked: 1}, "1 untracked file"},
+		{git.UnsavedWork{Changed: 2, Unpushed: 1}, "2 modified files and 1 unpushed commit"},
+		{git.UnsavedWork{Changed: 1, Untracked: 3, Stashes: 2, Unpushed: 4}, "1 modified file, 3 untracked files, 2 stashes and 4 unpushed commits"},
+	}
+	for _, tt := range tests {
+		if got := describeUnsavedWork(tt.work); got != tt.want {
+			t.Errorf("describeUnsavedWork(%+v) = %q, want %q", tt.work, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/shell"
+	"github.com/niczy/wt/internal/trash"
+)
+
+// CompletionFlag describes a command-line flag for completion
+type CompletionFlag struct {
+	// Name is the flag name without dashes
+	Name string
+	// Usage describes the flag
+	Usage string
+	// TakesValue is set for flags that read the next argument
+	TakesValue bool
+	// Command is set for the flags of the original CLI that stand for a
+	// command, such as -c for create
+	Command string
+}
+
+// CompletionCommand describes a command for completion
+type CompletionCommand struct {
+	Name    string
+	Aliases []string
+	Summary string
+	Flags   []CompletionFlag
+}
+
+// CompleteOptions holds settings for Complete
+type CompleteOptions struct {
+	// Commands are the commands that may be completed
+	Commands []CompletionCommand
+	// Flags are the flags accepted before a command is named
+	Flags []CompletionFlag
+}
+
+// candidate is a completion candidate with an optional description
+type candidate struct {
+	value, desc string
+}
+
+// Complete handles the hidden "wt __complete" command used by the
+// completion scripts. words are the arguments typed after wt, the last
+// being the one to complete. It prints the candidates for that word, one
+// per line as value, tab, description; the shell filters them.
+// Completion must never get in the way, so errors just mean no candidates.
+func Complete(words []string, opts CompleteOptions) error {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	for _, c := range completeWords(words, opts) {
+		if c.desc == "" {
+			fmt.Println(c.value)
+		} else {
+			fmt.Printf("%s\t%s\n", c.value, c.desc)
+		}
+	}
+	return nil
+}
+
+// completeWords returns the candidates for the last of words
+func completeWords(words []string, opts CompleteOptions) []candidate {
+	current := words[len(words)-1]
+
+	// Work out the command and its arguments from the words before, as
+	// the command line parser would: the first argument names the command
+	// or starts a query for go, and the legacy flags like -c name one too
+	var cmd *CompletionCommand
+	flags := opts.Flags
+	var args []string
+	// valueOf is the flag the word being completed is the value of
+	var valueOf *CompletionFlag
+	for i := 0; i < len(words)-1; i++ {
+		word := words[i]
+		if f, ok := lookupCompletionFlag(flags, word); ok {
+			if f.Command != "" && cmd == nil {
+				cmd = lookupCompletionCommand(opts.Commands, f.Command)
+				if cmd != nil {
+					flags = cmd.Flags
+				}
+			}
+			if f.TakesValue {
+				if i+1 == len(words)-1 {
+					valueOf = &f
+					break
+				}
+				i++
+				if f.Command != "" {
+					args = append(args, words[i])
+				}
+			}
+			continue
+		}
+		if cmd == nil && len(args) == 0 && !strings.HasPrefix(word, "-") {
+			if cmd = lookupCompletionCommand(opts.Commands, word); cmd != nil {
+				flags = cmd.Flags
+				continue
+			}
+			cmd = lookupCompletionCommand(opts.Commands, "go")
+		}
+		args = append(args, word)
+	}
+
+	if valueOf != nil {
+		return completeFlagValue(valueOf.Name)
+	}
+
+	if strings.HasPrefix(current, "-") {
+		return completeFlags(flags)
+	}
+
+	if cmd == nil {
+		candidates := completeWorktrees()
+		for _, c := range opts.Commands {
+			candidates = append(candidates, candidate{c.Name, c.Summary})
+		}
+		return candidates
+	}
+	return completeArg(cmd.Name, args, opts)
+}
+
+// completeArg returns the candidates for the argument of the named command
+// that follows args
+func completeArg(name string, args []string, opts CompleteOptions) []candidate {
+	switch name {
+	case "go", "rm":
+		// Every argument is a term of the query
+		return completeWorktrees()
+	}
+	if len(args) > 0 {
+		return nil
+	}
+	switch name {
+	case "create":
+		return completeBranches()
+	case "forget":
+		return completeWorktrees()
+	case "config":
+		return completeConfigKeys()
+	case "trash":
+		return completeNames([]string{"ls"})
+	case "undo":
+		return completeTrash()
+	case "init":
+		return completeNames(shell.Names())
+	case "completion":
+		return completeNames(shell.CompletionNames())
+	case "help":
+		var candidates []candidate
+		for _, c := range opts.Commands {
+			candidates = append(candidates, candidate{c.Name, c.Summary})
+		}
+		return candidates
+	}
+	return nil
+}
+
+// lookupCompletionCommand finds a command by name or alias
+func lookupCompletionCommand(cmds []CompletionCommand, name string) *CompletionCommand {
+	for i, c := range cmds {
+		if c.Name == name {
+			return &cmds[i]
+		}
+		for _, alias := range c.Aliases {
+			if alias == name {
+				return &cmds[i]
+			}
+		}
+	}
+	return nil
+}
+
+// lookupCompletionFlag finds the flag spelled as word, e.g. "-c", "--from"
+func lookupCompletionFlag(flags []CompletionFlag, word string) (CompletionFlag, bool) {
+	if !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return CompletionFlag{}, false
+	}
+	name := strings.TrimLeft(word, "-")
+	for _, f := range flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return CompletionFlag{}, false
+}
+
+// completeFlags returns the flags, with one dash for single-letter names
+// and two otherwise, as the usage text writes them
+func completeFlags(flags []CompletionFlag) []candidate {
+	candidates := make([]candidate, 0, len(flags))
+	for _, f := range flags {
+		prefix := "--"
+		if len(f.Name) == 1 {
+			prefix = "-"
+		}
+		candidates = append(candidates, candidate{prefix + f.Name, f.Usage})
+	}
+	return candidates
+}
+
+// completeFlagValue returns the candidates for the value of the named flag
+func completeFlagValue(name string) []candidate {
+	switch name {
+	case "c", "branch":
+		return completeBranches()
+	case "from", "base":
+		return completeRefs()
+	case "d", "forget":
+		// The legacy flags -d and --forget take a worktree
+		return completeWorktrees()
+	case "color":
+		return completeNames([]string{"auto", "always", "never"})
+	}
+	return nil
+}
+
+// completeWorktrees returns the names of the worktrees, described by
+// their branch
+func completeWorktrees() []candidate {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	worktrees, err := listWorktrees(cfg)
+	if err != nil {
+		return nil
+	}
+	candidates := make([]candidate, 0, len(worktrees))
+	for _, wt := range worktrees {
+		candidates = append(candidates, candidate{wt.Name, wt.Branch})
+	}
+	return candidates
+}
+
+// completeTrash returns the names of the deleted worktrees in the trash,
+// described by their branch
+func completeTrash() []candidate {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	entries, err := trash.Open(cfg.Get(config.KeyDataDir)).List()
+	if err != nil {
+		return nil
+	}
+	candidates := make([]candidate, 0, len(entries))
+	for _, e := range entries {
+		candidates = append(candidates, candidate{e.Name, e.Branch})
+	}
+	return candidates
+}
+
+// completeBranches returns the local branches of the current repository
+// and the remote ones without their remote, which git worktree add checks
+// out as a new tracking branch
+func completeBranches() []candidate {
+	var candidates []candidate
+	seen := map[string]bool{}
+	local, _ := git.ListRefs("refs/heads/")
+	for _, name := range local {
+		seen[name] = true
+		candidates = append(candidates, candidate{name, "local branch"})
+	}
+	remote, _ := git.ListRefs("refs/remotes/")
+	for _, ref := range remote {
+		_, name, ok := strings.Cut(ref, "/")
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		candidates = append(candidates, candidate{name, ref})
+	}
+	return candidates
+}
+
+// completeRefs returns the branches, remote-tracking branches and tags of
+// the current repository
+func completeRefs() []candidate {
+	var candidates []candidate
+	for _, kind := range []struct{ prefix, desc string }{
+		{"refs/heads/", "local branch"},
+		{"refs/remotes/", "remote branch"},
+		{"refs/tags/", "tag"},
+	} {
+		names, _ := git.ListRefs(kind.prefix)
+		for _, name := range names {
+			candidates = append(candidates, candidate{name, kind.desc})
+		}
+	}
+	return candidates
+}
+
+// completeConfigKeys returns the config keys with their descriptions
+func completeConfigKeys() []candidate {
+	cfg, err := config.Load()
+	if err != nil {
+		return completeNames(config.Keys())
+	}
+	values := cfg.Values()
+	candidates := make([]candidate, 0, len(values))
+	for _, v := range values {
+		candidates = append(candidates, candidate{v.Key, v.Description})
+	}
+	return candidates
+}
+
+// completeNames returns names as candidates without descriptions
+func completeNames(names []string) []candidate {
+	candidates := make([]candidate, len(names))
+	for i, name := range names {
+		candidates[i] = candidate{value: name}
+	}
+	return candidates
+}
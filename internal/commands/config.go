@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/niczy/wt/internal/config"
+)
+
+// loadConfig loads the configuration and warns about the settings it
+// ignored
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, withCode(CodeConfig, err)
+	}
+	for _, warning := range cfg.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	return cfg, nil
+}
+
+// ShowConfig prints the effective configuration and where each value came from
+func ShowConfig() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	userPath, err := config.UserConfigPath()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User config: %s\n", userPath)
+	if len(cfg.Files) > 0 {
+		fmt.Println("Loaded:")
+		for _, file := range cfg.Files {
+			fmt.Printf("  %s\n", file)
+		}
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, v := range cfg.Values() {
+		value := strconv.Quote(v.Value)
+		if v.Value == "true" || v.Value == "false" {
+			value = v.Value
+		}
+		fmt.Fprintf(w, "%s\t= %s\t(%s)\n", v.Key, value, v.Source)
+	}
+	return w.Flush()
+}
+
+// ShowConfigValue prints the effective value of a single config key
+func ShowConfigValue(key string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	for _, v := range cfg.Values() {
+		if v.Key == key {
+			fmt.Println(v.Value)
+			return nil
+		}
+	}
+	return errorf(CodeInvalidInput, "unknown config key '%s'", key)
+}
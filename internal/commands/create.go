@@ -4,48 +4,140 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"unicode"
 
+	"github.com/niczy/wt/internal/config"
 	"github.com/niczy/wt/internal/git"
 )
 
-// Create handles the -c flag to create a new worktree
-func Create(worktreeName string) error {
-	// Get WT_HOME
-	wtHome, err := git.GetWTHome()
+// CreateOptions holds optional settings for Create
+type CreateOptions struct {
+	// From is the ref (branch, tag, commit or remote-tracking ref) the new
+	// branch starts at. When empty, the base_ref config value is used for
+	// new branches, and an existing branch is checked out as is.
+	From string
+	// Branch is the branch to check out or create. When empty, the
+	// worktree name is used as the branch name.
+	Branch string
+	// Format selects the output format
+	Format Format
+}
+
+// Create handles "wt create" (or -c) to create a new worktree
+func Create(worktreeName string, opts CreateOptions) error {
+	cfg, err := loadConfig()
 	if err != nil {
 		return err
 	}
+	if opts.From != "" {
+		if err := cfg.Set(config.KeyBaseRef, opts.From, config.SourceFlag+" --from"); err != nil {
+			return withCode(CodeInvalidInput, err)
+		}
+	}
+
+	// Get current repo
+	repoRoot, err := git.GetRepoRoot()
+	if err != nil {
+		return withCode(CodeNotARepo, err)
+	}
+
+	branchName := opts.Branch
+	if branchName == "" {
+		branchName = worktreeName
+	}
 
-	// Ensure WT_HOME exists
-	if err := os.MkdirAll(wtHome, 0755); err != nil {
-		return fmt.Errorf("failed to create WT_HOME directory: %w", err)
+	// Branch names may contain slashes, so the directory uses a slug
+	slug := slugify(worktreeName)
+	if slug == "" {
+		return errorf(CodeInvalidInput, "invalid worktree name: '%s'", worktreeName)
 	}
 
-	// Get current repo name
-	repoName, err := git.GetRepoName()
+	// Construct target path from the template, {repo}-{name} under
+	// WT_HOME by default
+	vars := templateVars(cfg)
+	vars.Repo = filepath.Base(repoRoot)
+	vars.RepoParent = filepath.Dir(repoRoot)
+	vars.Name = slug
+	vars.Branch = slugify(branchName)
+	targetPath, err := config.ExpandPathTemplate(cfg.Get(config.KeyPathTemplate), vars)
 	if err != nil {
-		return err
+		return withCode(CodeConfig, err)
 	}
 
-	// Construct target path: {WT_HOME}/{REPO_NAME}-{WORKTREE_NAME}
-	targetDirName := fmt.Sprintf("%s-%s", repoName, worktreeName)
-	targetPath := filepath.Join(wtHome, targetDirName)
+	// Ensure the parent directory exists
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
 
 	// Check if worktree already exists
 	if _, err := os.Stat(targetPath); err == nil {
-		return fmt.Errorf("worktree already exists at: %s", targetPath)
+		return errorf(CodeExists, "worktree already exists at: %s", targetPath)
 	}
 
-	// Create the worktree with the worktree name as branch name
-	if err := git.CreateWorktree(targetPath, worktreeName); err != nil {
-		return err
+	// An explicit --from always creates a new branch; a configured base ref
+	// only applies when the branch doesn't exist yet
+	baseRef := cfg.Get(config.KeyBaseRef)
+	if baseRef != "" && (opts.From != "" || !git.BranchExists(branchName)) {
+		err = git.CreateWorktreeFrom(targetPath, branchName, baseRef)
+	} else {
+		err = git.CreateWorktree(targetPath, branchName)
+	}
+	if err != nil {
+		return withCode(CodeGit, err)
+	}
+
+	wt := createdWorktree(cfg, targetPath, branchName)
+	if opts.Format == FormatText {
+		fmt.Printf("Created worktree at: %s\n", targetPath)
+	}
+
+	if err := runHook(cfg, config.KeyHookPostCreate, targetPath, wt); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if opts.Format != FormatText {
+		return writeWorktree(opts.Format, wt)
 	}
 
-	fmt.Printf("Created worktree at: %s\n", targetPath)
 	fmt.Printf("To enter the worktree, run: cd %s\n", targetPath)
-	
-	// Print the path for shell integration
-	fmt.Printf("WT_CD_PATH=%s\n", targetPath)
 
-	return nil
+	// The shell integration enters the new worktree, so it counts as a
+	// visit for wt - and frecency
+	recordVisit(loadHistory(cfg), wt)
+	return emitCDPath(targetPath)
+}
+
+// createdWorktree describes the worktree just created at path, as git
+// reports it when possible
+func createdWorktree(cfg *config.Config, path, branchName string) git.Worktree {
+	name := filepath.Base(path)
+	if rel, err := filepath.Rel(cfg.Get(config.KeyWTHome), path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = filepath.ToSlash(rel)
+	}
+
+	if wt, ok := lookupWorktree(path, name); ok {
+		return wt
+	}
+	return git.Worktree{Name: name, Path: path, Branch: branchName}
+}
+
+// slugify turns a worktree or branch name into a safe directory name.
+// Runs of characters other than letters, digits, '.', '_' and '-' become a
+// single '-', e.g. "feature/JIRA-123 login" becomes "feature-JIRA-123-login".
+// Letters and digits of any script are kept, along with combining marks,
+// so "café" and "日本" stay as they are.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-.")
 }
@@ -1,97 +1,505 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/niczy/wt/internal/fuzzy"
+	"github.com/niczy/wt/internal/config"
 	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/trash"
 )
 
-// Delete handles the -d flag to delete a worktree
-func Delete(pattern string) error {
-	worktrees, err := git.ListWorktrees()
+// deleteWorkers bounds how many repositories Delete removes worktrees
+// from at once
+const deleteWorkers = 4
+
+// DeleteOptions holds optional settings for Delete
+type DeleteOptions struct {
+	// Format selects the output format
+	Format Format
+	// Color overrides the color setting: auto, always or never
+	Color string
+	// Force deletes a worktree even if it has uncommitted changes,
+	// untracked files, stashes or unpushed commits
+	Force bool
+	// DeleteBranch also deletes the worktree's branch if it is merged into
+	// the base, overriding the delete_branch setting
+	DeleteBranch bool
+	// ForceDeleteBranch also deletes the worktree's branch, merged or not
+	ForceDeleteBranch bool
+	// AllMatching deletes every worktree a query matches instead of asking
+	// which one is meant
+	AllMatching bool
+	// Yes deletes without asking for confirmation
+	Yes bool
+	// NoInput never prompts, like the noninteractive setting: ambiguous
+	// queries fail, and nothing is deleted without Yes
+	NoInput bool
+}
+
+// deleteTarget is a worktree being deleted, and what became of it
+type deleteTarget struct {
+	wt git.Worktree
+	// clean is set when it is known to have no unsaved work
+	clean bool
+	plan  branchPlan
+	// deleted, deletedBranch and err record the outcome
+	deleted       bool
+	deletedBranch string
+	err           error
+}
+
+// Delete handles "wt rm" (or -d) to delete worktrees. Each pattern is a
+// query for one worktree, or with AllMatching for every worktree it
+// matches. Nothing is deleted unless all of them resolve and pass the
+// safety checks; then a single confirmation covers every target, and
+// worktrees of different repositories are removed in parallel.
+func Delete(patterns []string, opts DeleteOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if opts.Color != "" {
+		if err := cfg.Set(config.KeyColor, opts.Color, config.SourceFlag+" --color"); err != nil {
+			return withCode(CodeInvalidInput, err)
+		}
+	}
+	if opts.DeleteBranch {
+		if err := cfg.Set(config.KeyDeleteBranch, "true", config.SourceFlag+" --delete-branch"); err != nil {
+			return withCode(CodeInvalidInput, err)
+		}
+	}
+	if err := setNoInput(cfg, opts.NoInput); err != nil {
+		return err
+	}
+
+	worktrees, err := listWorktrees(cfg)
 	if err != nil {
 		return err
 	}
 
+	// The main worktree can't be removed, only the repository itself
+	worktrees = filterWorktrees(worktrees, func(wt git.Worktree) bool { return !wt.Main })
 	if len(worktrees) == 0 {
-		return fmt.Errorf("no worktrees found in WT_HOME")
+		return errorf(CodeNoWorktrees, "no worktrees found in WT_HOME")
 	}
 
-	matches := fuzzy.FuzzyMatch(pattern, worktrees)
-	if len(matches) == 0 {
-		return fmt.Errorf("no worktree matching '%s' found", pattern)
+	selected, err := selectDeleteTargets(cfg, worktrees, patterns, opts.AllMatching)
+	if err != nil {
+		return err
 	}
 
-	var selected string
-	if len(matches) == 1 {
-		selected = matches[0].Text
-	} else {
-		// Multiple matches, ask user to choose
-		selected, err = promptDeleteSelection(matches)
+	targets := make([]*deleteTarget, len(selected))
+	for i, wt := range selected {
+		if wt.Locked {
+			reason := ""
+			if wt.LockReason != "" {
+				reason = ": " + wt.LockReason
+			}
+			return errorf(CodeLocked, "worktree '%s' is locked%s (run 'git worktree unlock %s' first)", wt.Name, reason, wt.Path)
+		}
+		clean, err := checkUnsavedWork(wt, opts.Force)
 		if err != nil {
 			return err
 		}
+		targets[i] = &deleteTarget{wt: wt, clean: clean, plan: planBranch(cfg, wt, opts.ForceDeleteBranch)}
 	}
 
-	wtHome, err := git.GetWTHome()
-	if err != nil {
-		return err
+	// Confirm deletion. The question says which branches are kept, so
+	// only unasked deletions warn about them.
+	asked := false
+	switch {
+	case opts.Yes:
+	case cfg.Bool(config.KeyNonInteractive):
+		what := fmt.Sprintf("worktree '%s'", selected[0].Name)
+		if len(targets) > 1 {
+			what = countOf(len(targets), "worktree", "worktrees")
+		}
+		return errorf(CodeNeedsYes, "not deleting %s without --yes since prompts are disabled", what)
+	case cfg.Bool(config.KeyConfirmDelete):
+		question := fmt.Sprintf("Delete worktree '%s'%s?", worktreeLabel(selected[0]), targets[0].plan.describe())
+		if len(targets) > 1 {
+			fmt.Fprintln(os.Stderr, "Worktrees to delete:")
+			for _, t := range targets {
+				fmt.Fprintf(os.Stderr, "  %s%s\n", worktreeLabel(t.wt), t.plan.describe())
+			}
+			question = fmt.Sprintf("Delete %d worktrees?", len(targets))
+		}
+		ok, err := confirm(question)
+		if err != nil {
+			return err
+		}
+		asked = true
+		if !ok {
+			return errorf(CodeCancelled, "deletion cancelled")
+		}
 	}
 
-	targetPath := filepath.Join(wtHome, selected)
+	removeTargets(cfg, targets, opts.Force)
 
-	// Confirm deletion
-	fmt.Fprintf(os.Stderr, "Delete worktree '%s'? [y/N]: ", selected)
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
+	failed := 0
+	for _, t := range targets {
+		if t.err != nil {
+			failed++
+			if len(targets) > 1 {
+				fmt.Fprintf(os.Stderr, "Warning: failed to delete '%s': %v\n", t.wt.Name, t.err)
+			}
+			continue
+		}
+		if !t.plan.delete && t.plan.reason != "" && !asked {
+			fmt.Fprintf(os.Stderr, "Warning: kept branch '%s': %s (use -D to delete it anyway)\n", t.plan.branch, t.plan.reason)
+		}
+	}
+	if len(targets) == 1 && failed == 1 {
+		return targets[0].err
+	}
+
+	switch {
+	case opts.Format != FormatText && len(targets) == 1:
+		if err := writeDeletedWorktree(opts.Format, selected[0], targets[0].deletedBranch); err != nil {
+			return err
+		}
+	case opts.Format != FormatText:
+		if err := writeDeleteResults(opts.Format, targets); err != nil {
+			return err
+		}
+	default:
+		for _, t := range targets {
+			if !t.deleted {
+				continue
+			}
+			fmt.Printf("Deleted worktree: %s\n", t.wt.Name)
+			if t.deletedBranch != "" {
+				fmt.Printf("Deleted branch: %s\n", t.deletedBranch)
+			}
+		}
+		if len(targets) > 1 {
+			fmt.Printf("Deleted %d of %d worktrees\n", len(targets)-failed, len(targets))
+		}
+	}
+
+	for _, t := range targets {
+		if !t.deleted {
+			continue
+		}
+		if err := runHook(cfg, config.KeyHookPostDelete, "", t.wt); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if failed > 0 {
+		return reported(errorf(CodeGit, "failed to delete %d of %d worktrees", failed, len(targets)))
+	}
+	return nil
+}
+
+// selectDeleteTargets resolves each pattern to the worktrees it is meant
+// to delete: its only match, all matches with allMatching, or those the
+// user selects. Worktrees selected twice are deleted once.
+func selectDeleteTargets(cfg *config.Config, worktrees []git.Worktree, patterns []string, allMatching bool) ([]git.Worktree, error) {
+	var selected []git.Worktree
+	seen := map[string]bool{}
+	for _, pattern := range deleteQueries(cfg, patterns) {
+		q := parseQuery(cfg, pattern)
+		matches := matchWorktrees(q, worktrees)
+		if len(matches) == 0 {
+			return nil, errorf(CodeNoMatch, "no worktree matching '%s' found", pattern)
+		}
+		if len(matches) > 1 && !allMatching {
+			// Multiple matches, ask user to choose
+			var err error
+			if matches, err = selectWorktrees(cfg, q, matches, "to delete"); err != nil {
+				return nil, err
+			}
+		}
+		for _, wt := range matches {
+			if !seen[wt.Path] {
+				seen[wt.Path] = true
+				selected = append(selected, wt)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// deleteQueries returns the queries the patterns of Delete stand for. A
+// pattern made only of negated terms, such as "!old", would match nearly
+// every worktree by itself, so it narrows each of the other patterns
+// instead; if all of them are like that, they form a single query.
+func deleteQueries(cfg *config.Config, patterns []string) []string {
+	var queries, filters []string
+	for _, pattern := range patterns {
+		if parseQuery(cfg, pattern).Negated() {
+			filters = append(filters, pattern)
+		} else {
+			queries = append(queries, pattern)
+		}
+	}
+	if len(filters) == 0 {
+		return queries
+	}
+	if len(queries) == 0 {
+		return []string{strings.Join(filters, " ")}
+	}
+	for i := range queries {
+		queries[i] += " " + strings.Join(filters, " ")
+	}
+	return queries
+}
+
+// removeTargets removes the worktrees of targets and their branches as
+// planned, recording the outcome in each. Removals in one repository run
+// one after another, since git serializes them anyway; different
+// repositories are handled in parallel.
+func removeTargets(cfg *config.Config, targets []*deleteTarget, force bool) {
+	var repos []string
+	byRepo := map[string][]*deleteTarget{}
+	for _, t := range targets {
+		if _, ok := byRepo[t.wt.Repo]; !ok {
+			repos = append(repos, t.wt.Repo)
+		}
+		byRepo[t.wt.Repo] = append(byRepo[t.wt.Repo], t)
 	}
 
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, deleteWorkers)
+	for _, repo := range repos {
+		wg.Add(1)
+		go func(group []*deleteTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			for _, t := range group {
+				if t.err = removeWorktree(cfg, t.wt, force, t.clean); t.err == nil {
+					t.deleted = true
+					t.deletedBranch = deleteBranch(t.plan)
+				}
+			}
+		}(byRepo[repo])
+	}
+	wg.Wait()
+
+	// Deleted worktrees shouldn't linger in the navigation history, and
+	// the trash may have expired entries to drop
+	hist := loadHistory(cfg)
+	forgotten := false
+	for _, t := range targets {
+		if t.deleted && len(hist.Forget(t.wt.Path)) > 0 {
+			forgotten = true
+		}
+	}
+	if forgotten {
+		saveHistory(hist)
+	}
+	if _, err := expireTrash(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from
+// stdin, defaulting to no
+func confirm(question string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	input, err := readLine()
+	if err != nil {
+		return false, errorf(CodeInvalidInput, "failed to read input: %w", err)
+	}
 	input = strings.TrimSpace(strings.ToLower(input))
-	if input != "y" && input != "yes" {
-		fmt.Println("Deletion cancelled")
-		return nil
+	return input == "y" || input == "yes", nil
+}
+
+// checkUnsavedWork refuses to throw away work in wt unless forced, and
+// only warns about it when forced. A worktree whose directory is gone has
+// nothing left to lose. It reports whether wt is known to have no unsaved
+// work.
+func checkUnsavedWork(wt git.Worktree, force bool) (bool, error) {
+	if wt.Prunable {
+		return false, nil
+	}
+	work, err := git.GetUnsavedWork(wt.Path, wt.Branch)
+	if err != nil {
+		if !force {
+			return false, errorf(CodeGit, "%v; use --force to delete '%s' anyway", err, wt.Name)
+		}
+		return false, nil
+	}
+	if !work.Empty() {
+		if !force {
+			return false, errorf(CodeDirty, "worktree '%s' has %s; use --force to delete it anyway", wt.Name, describeUnsavedWork(work))
+		}
+		fmt.Fprintf(os.Stderr, "Warning: worktree '%s' has %s\n", wt.Name, describeUnsavedWork(work))
+		return false, nil
+	}
+	return true, nil
+}
+
+// removeWorktree runs the pre_delete hook, moves wt to the trash unless
+// trash_days is 0 and removes it. force removes it despite unsaved work,
+// and clean allows deleting its directory by hand if git can't.
+func removeWorktree(cfg *config.Config, wt git.Worktree, force, clean bool) error {
+	if err := runHook(cfg, config.KeyHookPreDelete, wt.Path, wt); err != nil {
+		return withCode(CodeHook, err)
 	}
 
-	// Remove the worktree
-	if err := git.RemoveWorktree(targetPath); err != nil {
-		// If git worktree remove fails, try to remove the directory manually
-		fmt.Fprintf(os.Stderr, "Warning: git worktree remove failed, attempting manual removal: %v\n", err)
-		if err := os.RemoveAll(targetPath); err != nil {
-			return fmt.Errorf("failed to remove worktree directory: %w", err)
+	// A worktree whose directory is gone has nothing left to save
+	trashed := false
+	var entry trash.Entry
+	if !wt.Prunable && cfg.Days(config.KeyTrashDays) > 0 {
+		var err error
+		if entry, err = trashWorktree(cfg, wt); err != nil {
+			return errorf(CodeGit, "%v (set trash_days = 0 to delete without the trash)", err)
 		}
+		trashed = true
 	}
+	if err := removeWorktreeFiles(wt, force, clean); err != nil {
+		// The worktree is still there, so there is nothing to undo
+		if trashed {
+			dropTrash(cfg, entry)
+		}
+		return err
+	}
+	return nil
+}
 
-	fmt.Printf("Deleted worktree: %s\n", selected)
+// removeWorktreeFiles removes wt with git, or by hand if git can't and it
+// is clean
+func removeWorktreeFiles(wt git.Worktree, force, clean bool) error {
+	if wt.Prunable {
+		// The directory is already gone; only the metadata is left. Forcing
+		// git to remove the worktree drops it for this one alone, where
+		// git worktree prune would drop that of every missing worktree.
+		if err := git.RemoveWorktree(wt.Repo, wt.Path, true); err != nil {
+			return withCode(CodeGit, err)
+		}
+	} else if err := git.RemoveWorktree(wt.Repo, wt.Path, force); err != nil {
+		// git can't remove some worktrees, e.g. ones with submodules. Those
+		// known to have no unsaved work can go by hand; anything else stays.
+		if !clean {
+			return withCode(CodeGit, err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: git worktree remove failed, attempting manual removal: %v\n", err)
+		if err := os.RemoveAll(wt.Path); err != nil {
+			return errorf(CodeGit, "failed to remove worktree directory: %w", err)
+		}
+		if err := git.RemoveWorktree(wt.Repo, wt.Path, true); err != nil {
+			return withCode(CodeGit, err)
+		}
+	}
 	return nil
 }
 
-// promptDeleteSelection prompts the user to select from multiple matches for deletion
-func promptDeleteSelection(matches []fuzzy.Match) (string, error) {
-	fmt.Fprintf(os.Stderr, "Multiple matches found:\n")
-	for i, match := range matches {
-		fmt.Fprintf(os.Stderr, "  [%d] %s\n", i+1, match.Text)
+// deleteBranch carries out plan once the worktree is gone, and returns
+// the deleted branch, if any. The worktree is gone either way, so a branch
+// that can't be deleted only warrants a warning.
+func deleteBranch(plan branchPlan) string {
+	if !plan.delete {
+		return ""
+	}
+	if err := git.DeleteBranch(plan.repo, plan.branch); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return ""
+	}
+	return plan.branch
+}
+
+// branchPlan is what happens to the branch of a worktree being deleted
+type branchPlan struct {
+	repo, branch string
+	// delete is set when the branch is deleted with the worktree, and
+	// force when that happens whether or not it is merged
+	delete, force bool
+	// reason explains the plan, e.g. "merged into main"
+	reason string
+}
+
+// planBranch decides whether to delete the branch of wt along with it:
+// always with force, and if it is merged into the base with the
+// delete_branch setting. The base is base_ref, or the branch checked out
+// in the repository's main worktree.
+func planBranch(cfg *config.Config, wt git.Worktree, force bool) branchPlan {
+	plan := branchPlan{repo: wt.Repo, branch: wt.Branch}
+	if wt.Branch == "" || (!force && !cfg.Bool(config.KeyDeleteBranch)) {
+		return plan
+	}
+	if force {
+		plan.delete, plan.force = true, true
+		return plan
 	}
-	fmt.Fprintf(os.Stderr, "Enter selection to delete (1-%d): ", len(matches))
 
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
+	base, err := baseRef(cfg, wt.Repo)
 	if err != nil {
-		return "", fmt.Errorf("failed to read input: %w", err)
+		plan.reason = "no base branch to check it is merged into"
+		return plan
 	}
+	if base == wt.Branch {
+		plan.reason = "it is the base branch"
+		return plan
+	}
+
+	merged, err := git.IsMerged(wt.Repo, wt.Branch, base)
+	switch {
+	case err != nil:
+		plan.reason = err.Error()
+	case merged:
+		plan.delete = true
+		plan.reason = "merged into " + base
+	default:
+		plan.reason = "not merged into " + base
+	}
+	return plan
+}
+
+// baseRef returns the ref branches of the repository at repo are merged
+// into: base_ref if set, otherwise the branch checked out in its main
+// worktree
+func baseRef(cfg *config.Config, repo string) (string, error) {
+	if base := cfg.Get(config.KeyBaseRef); base != "" {
+		return base, nil
+	}
+	return git.HeadBranch(repo)
+}
 
-	input = strings.TrimSpace(input)
-	selection, err := strconv.Atoi(input)
-	if err != nil || selection < 1 || selection > len(matches) {
-		return "", fmt.Errorf("invalid selection: %s", input)
+// describe completes the confirmation prompt "Delete worktree 'x'...?"
+func (p branchPlan) describe() string {
+	switch {
+	case p.force:
+		return fmt.Sprintf(" and force-delete branch '%s'", p.branch)
+	case p.delete:
+		return fmt.Sprintf(" and branch '%s' (%s)", p.branch, p.reason)
+	case p.reason != "":
+		return fmt.Sprintf(" but keep branch '%s' (%s; -D deletes it anyway)", p.branch, p.reason)
 	}
+	return ""
+}
 
-	return matches[selection-1].Text, nil
+// describeUnsavedWork lists the unsaved work in a worktree, e.g. "2
+// modified files and 1 unpushed commit"
+func describeUnsavedWork(work git.UnsavedWork) string {
+	var parts []string
+	for _, p := range []struct {
+		n         int
+		one, many string
+	}{
+		{work.Changed, "modified file", "modified files"},
+		{work.Untracked, "untracked file", "untracked files"},
+		{work.Stashes, "stash", "stashes"},
+		{work.Unpushed, "unpushed commit", "unpushed commits"},
+	} {
+		if p.n > 0 {
+			parts = append(parts, countOf(p.n, p.one, p.many))
+		}
+	}
+	if len(parts) < 2 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+// countOf writes n of something, e.g. "1 stash" or "2 stashes"
+func countOf(n int, one, many string) string {
+	if n == 1 {
+		return "1 " + one
+	}
+	return fmt.Sprintf("%d %s", n, many)
 }
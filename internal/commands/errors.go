@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/niczy/wt/internal/git"
+)
+
+// Error codes reported in machine-readable output
+const (
+	CodeNoWorktrees  = "no_worktrees"
+	CodeNoMatch      = "no_match"
+	CodeAmbiguous    = "ambiguous"
+	CodeNotARepo     = "not_a_repo"
+	CodeExists       = "already_exists"
+	CodeLocked       = "locked"
+	CodeDirty        = "unsaved_work"
+	CodeInvalidInput = "invalid_input"
+	CodeCancelled    = "cancelled"
+	CodeNeedsYes     = "confirmation_required"
+	CodeConfig       = "config_error"
+	CodeHook         = "hook_failed"
+	CodeGit          = "git_failed"
+	CodeUnknown      = "error"
+)
+
+// Sentinel errors for the error codes, for use with errors.Is. An *Error
+// matches the sentinel of its code.
+var (
+	ErrNoWorktrees  = errors.New("no worktrees found")
+	ErrNoMatch      = errors.New("no matching worktree")
+	ErrAmbiguous    = errors.New("several worktrees match")
+	ErrNotARepo     = git.ErrNotARepo
+	ErrExists       = errors.New("already exists")
+	ErrLocked       = errors.New("worktree is locked")
+	ErrDirty        = errors.New("worktree has unsaved work")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrCancelled    = errors.New("cancelled")
+	ErrNeedsYes     = errors.New("confirmation required")
+	ErrConfig       = errors.New("invalid configuration")
+	ErrHook         = errors.New("hook failed")
+	ErrGit          = errors.New("git command failed")
+)
+
+// errorCodes lists the error codes with their sentinel error and the
+// exit status wt ends with; anything else exits with 1
+var errorCodes = []struct {
+	code string
+	err  error
+	exit int
+}{
+	{CodeInvalidInput, ErrInvalidInput, 2},
+	{CodeNoMatch, ErrNoMatch, 3},
+	{CodeAmbiguous, ErrAmbiguous, 4},
+	{CodeNoWorktrees, ErrNoWorktrees, 5},
+	{CodeCancelled, ErrCancelled, 6},
+	{CodeNeedsYes, ErrNeedsYes, 7},
+	{CodeDirty, ErrDirty, 8},
+	{CodeLocked, ErrLocked, 9},
+	{CodeExists, ErrExists, 10},
+	{CodeNotARepo, ErrNotARepo, 11},
+	{CodeConfig, ErrConfig, 12},
+	{CodeHook, ErrHook, 13},
+	{CodeGit, ErrGit, 14},
+}
+
+// gitErrors maps the sentinel errors of the git package to the codes of
+// the failures they stand for; other git errors get CodeGit
+var gitErrors = []struct {
+	err  error
+	code string
+}{
+	{git.ErrNotARepo, CodeNotARepo},
+	{git.ErrRefNotFound, CodeInvalidInput},
+	{git.ErrBranchExists, CodeExists},
+	{git.ErrDirty, CodeDirty},
+	{git.ErrLocked, CodeLocked},
+}
+
+// gitErrorCode returns the code of the git sentinel error err wraps, if
+// any
+func gitErrorCode(err error) (string, bool) {
+	for _, g := range gitErrors {
+		if errors.Is(err, g.err) {
+			return g.code, true
+		}
+	}
+	return "", false
+}
+
+// Error is an error with a stable code for machine-readable output
+type Error struct {
+	Code string
+	Err  error
+	// Candidates lists the worktrees an ambiguous query matched
+	Candidates []git.Worktree
+	// Reported is set when the command's machine-readable output already
+	// describes the failure, so it goes to stderr instead
+	Reported bool
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the sentinel error of e's code
+func (e *Error) Is(target error) bool {
+	for _, c := range errorCodes {
+		if c.code == e.Code {
+			return c.err == target
+		}
+	}
+	return false
+}
+
+// errorf returns an *Error with the given code and formatted message
+func errorf(code, format string, args ...interface{}) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// withCode attaches a code to err, keeping any code it already has and
+// preferring the one of a git sentinel error it wraps
+func withCode(code string, err error) error {
+	var coded *Error
+	if err == nil || errors.As(err, &coded) {
+		return err
+	}
+	if gitCode, ok := gitErrorCode(err); ok {
+		code = gitCode
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// reported marks err as described by the command's output already
+func reported(err error) error {
+	var coded *Error
+	if errors.As(err, &coded) {
+		coded.Reported = true
+	}
+	return err
+}
+
+// ErrorCode returns the code of err, or of the sentinel error it wraps,
+// from this package or internal/git, or CodeUnknown if it has none
+func ErrorCode(err error) string {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	if code, ok := gitErrorCode(err); ok {
+		return code
+	}
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return CodeUnknown
+}
+
+// ExitCode returns the exit status for err: 0 for nil, the status of its
+// code, or 1
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	code := ErrorCode(err)
+	for _, c := range errorCodes {
+		if c.code == code {
+			return c.exit
+		}
+	}
+	return 1
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/history"
+)
+
+// ForgetOptions holds optional settings for Forget
+type ForgetOptions struct {
+	// Format selects the output format
+	Format Format
+}
+
+// Forget handles "wt forget" to remove a worktree, given by name or
+// path, from the navigation history
+func Forget(name string, opts ForgetOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	hist, err := history.Load(cfg.Get(config.KeyDataDir))
+	if err != nil {
+		return err
+	}
+
+	removed := hist.Forget(name)
+	if len(removed) == 0 {
+		// Allow a relative path, e.g. wt forget .
+		if abs, err := filepath.Abs(name); err == nil {
+			removed = hist.Forget(abs)
+		}
+	}
+	if len(removed) == 0 {
+		return errorf(CodeNoMatch, "'%s' is not in the navigation history", name)
+	}
+	if err := hist.Save(time.Now()); err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case FormatJSON:
+		infos := make([]historyInfo, len(removed))
+		for i, e := range removed {
+			infos[i] = newHistoryInfo(e)
+		}
+		return writeJSON(struct {
+			Forgotten []historyInfo `json:"forgotten"`
+		}{infos})
+	case FormatPorcelain:
+		for _, e := range removed {
+			writeHistoryRecord(os.Stdout, e)
+		}
+	default:
+		for _, e := range removed {
+			fmt.Printf("Forgot %s (%s)\n", e.Name, e.Path)
+		}
+	}
+	return nil
+}
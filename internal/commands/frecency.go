@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/history"
+)
+
+const (
+	// frecencyWeight scales the boost a match gets from its frecency. A
+	// single visit in the last hour is worth frecencyWeight points; ten
+	// are worth about 3.5 times that.
+	frecencyWeight = 10
+	// minDominantFrecency is the frecency the best match needs before it
+	// is selected without asking, about three visits in the last day
+	minDominantFrecency = 240
+	// dominanceRatio is how many times the frecency of every other match
+	// the best match needs before it is selected without asking
+	dominanceRatio = 4
+)
+
+// loadHistory reads the navigation history from the data directory. A
+// history that can't be read is reported and treated as empty.
+func loadHistory(cfg *config.Config) *history.History {
+	hist, err := history.Load(cfg.Get(config.KeyDataDir))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return hist
+}
+
+// saveHistory writes the navigation history back, warning on failure
+// since it only affects ranking
+func saveHistory(hist *history.History) {
+	if err := hist.Save(time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// recordVisit adds a visit to wt to the navigation history
+func recordVisit(hist *history.History, wt git.Worktree) {
+	hist.Visit(wt.Name, wt.Path, time.Now())
+	saveHistory(hist)
+}
+
+// rankByFrecency adds a boost for how frequently and recently each match
+// was visited, then re-sorts the matches. It reports whether the best
+// match dominates the others clearly enough to select it without asking:
+// it must have been visited a few times recently, and more than
+// dominanceRatio times as much as any other match.
+func rankByFrecency(matches []scoredWorktree, hist *history.History, now time.Time) bool {
+	for i := range matches {
+		frecency := hist.Frecency(matches[i].wt.Path, now)
+		matches[i].frecency = frecency
+		matches[i].match.Score += int(frecencyWeight * math.Log2(1+frecency/100))
+	}
+	sortScored(matches)
+
+	if len(matches) < 2 || matches[0].frecency < minDominantFrecency {
+		return false
+	}
+	for _, m := range matches[1:] {
+		if matches[0].frecency < dominanceRatio*m.frecency {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/history"
+)
+
+// HistoryOptions holds optional settings for Back and History
+type HistoryOptions struct {
+	// Format selects the output format
+	Format Format
+	// Root and Subdir override the preserve_subdir setting: Root enters
+	// the worktree root, Subdir the current subdirectory
+	Root, Subdir bool
+}
+
+// historyInfo is the JSON representation of a navigation history entry
+type historyInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Count     float64   `json:"count"`
+	LastVisit time.Time `json:"last_visit"`
+}
+
+func newHistoryInfo(e history.Entry) historyInfo {
+	return historyInfo{Name: e.Name, Path: e.Path, Count: e.Count, LastVisit: e.LastVisit()}
+}
+
+// writeHistoryRecord writes one history entry as porcelain lines followed
+// by a blank line
+func writeHistoryRecord(w io.Writer, e history.Entry) {
+	fmt.Fprintf(w, "worktree %s\n", e.Path)
+	fmt.Fprintf(w, "name %s\n", e.Name)
+	fmt.Fprintf(w, "count %g\n", e.Count)
+	fmt.Fprintf(w, "last-visit %d\n", e.LastVisit().Unix())
+	fmt.Fprintln(w)
+}
+
+// Back handles "wt back" (or "wt -") to return to the previously visited
+// worktree, like "cd -"
+func Back(opts HistoryOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := setPreserveSubdir(cfg, opts.Root, opts.Subdir); err != nil {
+		return err
+	}
+	hist := loadHistory(cfg)
+
+	// The worktree we are in doesn't count as previous
+	current := ""
+	if root, err := git.GetRepoRoot(); err == nil {
+		current = canonicalPath(root)
+	}
+
+	for _, e := range recentEntries(hist) {
+		if canonicalPath(e.Path) != current {
+			return enterWorktree(cfg, hist, historyWorktree(e), opts.Format)
+		}
+	}
+	return errorf(CodeNoMatch, "no previous worktree in the navigation history")
+}
+
+// History handles "wt history". With index 0 it lists recently visited
+// worktrees, most recent first; otherwise it goes to the worktree at that
+// position in the list, counting from 1.
+func History(index int, opts HistoryOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := setPreserveSubdir(cfg, opts.Root, opts.Subdir); err != nil {
+		return err
+	}
+	hist := loadHistory(cfg)
+	entries := recentEntries(hist)
+
+	if index != 0 {
+		if index < 0 || index > len(entries) {
+			return errorf(CodeInvalidInput, "no history entry %d (history has %d)", index, len(entries))
+		}
+		return enterWorktree(cfg, hist, historyWorktree(entries[index-1]), opts.Format)
+	}
+
+	switch opts.Format {
+	case FormatJSON:
+		infos := make([]historyInfo, len(entries))
+		for i, e := range entries {
+			infos[i] = newHistoryInfo(e)
+		}
+		return writeJSON(struct {
+			History []historyInfo `json:"history"`
+		}{infos})
+	case FormatPorcelain:
+		for _, e := range entries {
+			writeHistoryRecord(os.Stdout, e)
+		}
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No worktrees visited yet")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	now := time.Now()
+	for i, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, e.Name, formatAge(now.Sub(e.LastVisit())), e.Path)
+	}
+	return w.Flush()
+}
+
+// recentEntries returns the history entries whose directory still exists,
+// most recently visited first
+func recentEntries(hist *history.History) []history.Entry {
+	var entries []history.Entry
+	for _, e := range hist.Entries() {
+		if info, err := os.Stat(e.Path); err == nil && info.IsDir() {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// historyWorktree describes the worktree of a history entry, as git
+// reports it when possible
+func historyWorktree(e history.Entry) git.Worktree {
+	if wt, ok := lookupWorktree(e.Path, e.Name); ok {
+		return wt
+	}
+	return git.Worktree{Name: e.Name, Path: e.Path}
+}
+
+// canonicalPath resolves symlinks in path so equal directories compare
+// equal, e.g. /tmp and /private/tmp on macOS
+func canonicalPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/git"
+)
+
+// runHook runs the command configured for the given hook key, if any.
+// The command runs in dir with the worktree described in its environment:
+// WT_HOOK, WT_NAME, WT_PATH and WT_BRANCH. Hook output goes to stderr so it
+// never mixes with the WT_CD_PATH line on stdout.
+func runHook(cfg *config.Config, key, dir string, wt git.Worktree) error {
+	command := cfg.Get(key)
+	if command == "" {
+		return nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"WT_HOOK="+key,
+		"WT_NAME="+wt.Name,
+		"WT_PATH="+wt.Path,
+		"WT_BRANCH="+wt.Branch,
+	)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook failed: %w", key, err)
+	}
+	return nil
+}
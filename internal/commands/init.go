@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/niczy/wt/internal/shell"
+)
+
+// Init handles "wt init <shell>" to print the shell integration script
+func Init(shellName string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the wt binary: %w", err)
+	}
+	script, err := shell.Script(shellName, exe)
+	if err != nil {
+		return withCode(CodeInvalidInput, err)
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// Completion handles "wt completion <shell>" to print the completion script
+func Completion(shellName string) error {
+	script, err := shell.Completion(shellName)
+	if err != nil {
+		return withCode(CodeInvalidInput, err)
+	}
+	fmt.Print(script)
+	return nil
+}
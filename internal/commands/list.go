@@ -2,32 +2,219 @@ package commands
 
 import (
 	"fmt"
-	"path/filepath"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/niczy/wt/internal/config"
 	"github.com/niczy/wt/internal/git"
 )
 
+// statusWorkers bounds how many git processes List runs at once
+const statusWorkers = 8
+
+// ListOptions holds optional settings for List
+type ListOptions struct {
+	// Short prints only worktree paths instead of the status table, and
+	// leaves status out of machine-readable output
+	Short bool
+	// Format selects the output format
+	Format Format
+}
+
 // List shows all worktrees in WT_HOME
-func List() error {
-	wtHome, err := git.GetWTHome()
+func List(opts ListOptions) error {
+	cfg, err := loadConfig()
 	if err != nil {
 		return err
 	}
+	wtHome := cfg.Get(config.KeyWTHome)
 
-	worktrees, err := git.ListWorktrees()
+	worktrees, err := listWorktrees(cfg)
 	if err != nil {
 		return err
 	}
 
+	if opts.Format != FormatText {
+		return writeWorktreeList(opts, worktrees)
+	}
+
 	if len(worktrees) == 0 {
 		fmt.Printf("No worktrees found in %s\n", wtHome)
 		return nil
 	}
 
-	fmt.Printf("Worktrees in %s:\n", wtHome)
-	for _, wt := range worktrees {
-		fmt.Printf("  %s\n", filepath.Join(wtHome, wt))
+	if opts.Short {
+		fmt.Printf("Worktrees in %s:\n", wtHome)
+		for _, wt := range worktrees {
+			switch {
+			case wt.Prunable:
+				fmt.Printf("  %s (prunable: %s)\n", wt.Path, wt.PrunableReason)
+			case wt.Locked:
+				fmt.Printf("  %s (locked)\n", wt.Path)
+			default:
+				fmt.Printf("  %s\n", wt.Path)
+			}
+		}
+		return nil
+	}
+
+	statuses, errs := worktreeStatuses(worktrees)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBRANCH\tSTATUS\tUPSTREAM\tLAST COMMIT")
+	now := time.Now()
+	for i, wt := range worktrees {
+		branch := wt.Branch
+		if wt.Detached {
+			branch = "(detached " + shortHash(wt.Head) + ")"
+		}
+
+		var state, upstream, lastCommit string
+		switch {
+		case wt.Prunable:
+			state = "missing"
+		case errs[i] != nil:
+			state = "unknown"
+		default:
+			s := statuses[i]
+			state = describeChanges(s)
+			upstream = describeUpstream(s)
+			if s.Subject != "" {
+				lastCommit = formatAge(now.Sub(s.CommitTime)) + "  " + strings.ReplaceAll(s.Subject, "\t", " ")
+			}
+		}
+		if wt.Locked {
+			state += ", locked"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", wt.Name, orDash(branch), state, orDash(upstream), orDash(lastCommit))
+	}
+	return w.Flush()
+}
+
+// writeWorktreeList writes worktrees and, unless opts.Short is set, their
+// status in a machine-readable format
+func writeWorktreeList(opts ListOptions, worktrees []git.Worktree) error {
+	var statuses []git.Status
+	var errs []error
+	if !opts.Short {
+		statuses, errs = worktreeStatuses(worktrees)
+	}
+	status := func(i int) *git.Status {
+		if opts.Short || worktrees[i].Prunable || errs[i] != nil {
+			return nil
+		}
+		return &statuses[i]
+	}
+
+	if opts.Format == FormatJSON {
+		infos := make([]worktreeInfo, 0, len(worktrees))
+		for i, wt := range worktrees {
+			infos = append(infos, newWorktreeInfo(wt, status(i)))
+		}
+		return writeJSON(struct {
+			Worktrees []worktreeInfo `json:"worktrees"`
+		}{infos})
 	}
 
+	for i, wt := range worktrees {
+		writePorcelainRecord(os.Stdout, wt, status(i))
+	}
 	return nil
 }
+
+// worktreeStatuses gathers the status of each worktree concurrently.
+// Results and errors are indexed like worktrees; prunable worktrees are
+// skipped since their directory is gone.
+func worktreeStatuses(worktrees []git.Worktree) ([]git.Status, []error) {
+	statuses := make([]git.Status, len(worktrees))
+	errs := make([]error, len(worktrees))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, statusWorkers)
+	for i, wt := range worktrees {
+		if wt.Prunable {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			statuses[i], errs[i] = git.GetStatus(path)
+		}(i, wt.Path)
+	}
+	wg.Wait()
+
+	return statuses, errs
+}
+
+// describeChanges summarizes uncommitted changes, e.g. "2 changed, 1 untracked"
+func describeChanges(s git.Status) string {
+	if !s.Dirty() {
+		return "clean"
+	}
+	var parts []string
+	if s.Changed > 0 {
+		parts = append(parts, fmt.Sprintf("%d changed", s.Changed))
+	}
+	if s.Untracked > 0 {
+		parts = append(parts, fmt.Sprintf("%d untracked", s.Untracked))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeUpstream summarizes how a branch compares to its upstream,
+// e.g. "+2 -1" for two commits ahead and one behind
+func describeUpstream(s git.Status) string {
+	if s.Upstream == "" {
+		return ""
+	}
+	if s.Ahead == 0 && s.Behind == 0 {
+		return "up to date"
+	}
+	var parts []string
+	if s.Ahead > 0 {
+		parts = append(parts, fmt.Sprintf("+%d", s.Ahead))
+	}
+	if s.Behind > 0 {
+		parts = append(parts, fmt.Sprintf("-%d", s.Behind))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatAge formats a duration as a short relative age, e.g. "3h ago"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(d.Hours()/24/30))
+	default:
+		return fmt.Sprintf("%dy ago", int(d.Hours()/24/365))
+	}
+}
+
+// shortHash abbreviates a commit hash for display
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
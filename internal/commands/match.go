@@ -0,0 +1,70 @@
+package commands
+
+import (
+	"sort"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/fuzzy"
+	"github.com/niczy/wt/internal/git"
+)
+
+// parseQuery parses a search query, applying the ignore_accents setting
+func parseQuery(cfg *config.Config, query string) fuzzy.Query {
+	q := fuzzy.ParseQuery(query)
+	q.Literal = !cfg.Bool(config.KeyIgnoreAccents)
+	return q
+}
+
+// scoredWorktree is a worktree matched by a query. match.Text is the
+// worktree name and match.Score its ranking score.
+type scoredWorktree struct {
+	wt    git.Worktree
+	match fuzzy.Match
+	// frecency is how frequently and recently the worktree was visited,
+	// when ranked by rankByFrecency
+	frecency float64
+}
+
+// matchWorktrees matches a query against each worktree's directory name
+// and branch name; each term may match either. Returns the matching
+// worktrees sorted by score (higher is better), with shorter names first
+// among equal scores.
+func matchWorktrees(q fuzzy.Query, worktrees []git.Worktree) []git.Worktree {
+	return worktreesOf(scoreWorktrees(q, worktrees))
+}
+
+// scoreWorktrees is like matchWorktrees but keeps the scores
+func scoreWorktrees(q fuzzy.Query, worktrees []git.Worktree) []scoredWorktree {
+	var matches []scoredWorktree
+	for _, wt := range worktrees {
+		if score, _ := q.MatchFields(wt.Name, wt.Branch); score > 0 {
+			matches = append(matches, scoredWorktree{wt: wt, match: fuzzy.Match{Text: wt.Name, Score: score}})
+		}
+	}
+	sortScored(matches)
+	return matches
+}
+
+// sortScored sorts matches by score, keeping their order for ties
+func sortScored(matches []scoredWorktree) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		return fuzzy.Less(matches[i].match, matches[j].match)
+	})
+}
+
+// worktreesOf returns the worktrees of matches
+func worktreesOf(matches []scoredWorktree) []git.Worktree {
+	result := make([]git.Worktree, len(matches))
+	for i, m := range matches {
+		result[i] = m.wt
+	}
+	return result
+}
+
+// worktreeLabel returns the text shown for a worktree in selection prompts
+func worktreeLabel(wt git.Worktree) string {
+	if wt.Branch == "" {
+		return wt.Name
+	}
+	return wt.Name + " [" + wt.Branch + "]"
+}
@@ -1,77 +1,165 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
-	"github.com/niczy/wt/internal/fuzzy"
+	"github.com/niczy/wt/internal/config"
 	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/history"
+	"github.com/niczy/wt/internal/shell"
 )
 
-// Navigate handles the default command to enter a worktree directory
-func Navigate(pattern string) error {
-	worktrees, err := git.ListWorktrees()
+// NavigateOptions holds optional settings for Navigate
+type NavigateOptions struct {
+	// Format selects the output format
+	Format Format
+	// Color overrides the color setting: auto, always or never
+	Color string
+	// Root and Subdir override the preserve_subdir setting: Root enters
+	// the worktree root, Subdir the current subdirectory
+	Root, Subdir bool
+	// NoInput fails on ambiguous matches instead of asking, like the
+	// noninteractive setting
+	NoInput bool
+}
+
+// Navigate handles "wt go", the default command, to enter a worktree directory
+func Navigate(pattern string, opts NavigateOptions) error {
+	cfg, err := loadConfig()
 	if err != nil {
 		return err
 	}
+	if opts.Color != "" {
+		if err := cfg.Set(config.KeyColor, opts.Color, config.SourceFlag+" --color"); err != nil {
+			return withCode(CodeInvalidInput, err)
+		}
+	}
+	if err := setPreserveSubdir(cfg, opts.Root, opts.Subdir); err != nil {
+		return err
+	}
+	if err := setNoInput(cfg, opts.NoInput); err != nil {
+		return err
+	}
 
+	worktrees, err := listWorktrees(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Worktrees whose directory is gone can't be entered
+	worktrees = filterWorktrees(worktrees, func(wt git.Worktree) bool { return !wt.Prunable })
 	if len(worktrees) == 0 {
-		return fmt.Errorf("no worktrees found in WT_HOME")
+		return errorf(CodeNoWorktrees, "no worktrees found in WT_HOME")
 	}
 
-	matches := fuzzy.FuzzyMatch(pattern, worktrees)
+	q := parseQuery(cfg, pattern)
+	matches := scoreWorktrees(q, worktrees)
 	if len(matches) == 0 {
-		return fmt.Errorf("no worktree matching '%s' found", pattern)
+		return errorf(CodeNoMatch, "no worktree matching '%s' found", pattern)
+	}
+
+	// Rank the worktrees visited most often and most recently first
+	hist := loadHistory(cfg)
+	dominant := false
+	if cfg.Bool(config.KeyFrecency) {
+		dominant = rankByFrecency(matches, hist, time.Now())
 	}
 
-	var selected string
-	if len(matches) == 1 {
-		selected = matches[0].Text
+	var selected git.Worktree
+	if len(matches) == 1 || dominant {
+		selected = matches[0].wt
 	} else {
 		// Multiple matches, ask user to choose
-		selected, err = promptSelection(matches)
+		selected, err = selectWorktree(cfg, q, worktreesOf(matches), "")
 		if err != nil {
 			return err
 		}
 	}
 
-	wtHome, err := git.GetWTHome()
+	return enterWorktree(cfg, hist, selected, opts.Format)
+}
+
+// setPreserveSubdir applies the --root and --subdir flags to the
+// preserve_subdir setting
+func setPreserveSubdir(cfg *config.Config, root, subdir bool) error {
+	var err error
+	switch {
+	case root && subdir:
+		return errorf(CodeInvalidInput, "--root and --subdir cannot be used together")
+	case root:
+		err = cfg.Set(config.KeyPreserveSubdir, "false", config.SourceFlag+" --root")
+	case subdir:
+		err = cfg.Set(config.KeyPreserveSubdir, "true", config.SourceFlag+" --subdir")
+	}
 	if err != nil {
-		return err
+		return withCode(CodeInvalidInput, err)
 	}
-
-	targetPath := filepath.Join(wtHome, selected)
-
-	// Print the path for shell integration to capture
-	// The shell wrapper function will read this and cd to the path
-	fmt.Printf("WT_CD_PATH=%s\n", targetPath)
-
 	return nil
 }
 
-// promptSelection prompts the user to select from multiple matches
-func promptSelection(matches []fuzzy.Match) (string, error) {
-	fmt.Fprintf(os.Stderr, "Multiple matches found:\n")
-	for i, match := range matches {
-		fmt.Fprintf(os.Stderr, "  [%d] %s\n", i+1, match.Text)
+// enterWorktree records a visit to wt and hands it over: as a worktree
+// record in machine-readable formats, or as the directory the shell
+// integration cds to
+func enterWorktree(cfg *config.Config, hist *history.History, wt git.Worktree, format Format) error {
+	recordVisit(hist, wt)
+	if format != FormatText {
+		return writeWorktree(format, wt)
+	}
+	dir := wt.Path
+	if cfg.Bool(config.KeyPreserveSubdir) {
+		dir = subdirIn(wt.Path)
 	}
-	fmt.Fprintf(os.Stderr, "Enter selection (1-%d): ", len(matches))
+	return emitCDPath(dir)
+}
 
-	reader := bufio.NewReader(os.Stdin)
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		return "", fmt.Errorf("failed to read input: %w", err)
+// subdirIn returns the directory in the worktree at root that corresponds
+// to the current directory's path relative to its own worktree root. When
+// that doesn't exist, the nearest existing ancestor is used, down to root
+// itself.
+func subdirIn(root string) string {
+	rel := currentSubdir()
+	for rel != "." && rel != "" {
+		dir := filepath.Join(root, rel)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+		rel = filepath.Dir(rel)
 	}
+	return root
+}
 
-	input = strings.TrimSpace(input)
-	selection, err := strconv.Atoi(input)
-	if err != nil || selection < 1 || selection > len(matches) {
-		return "", fmt.Errorf("invalid selection: %s", input)
+// currentSubdir returns the current directory relative to the root of the
+// worktree it is in, or "." outside a worktree
+func currentSubdir() string {
+	root, err := git.GetRepoRoot()
+	if err != nil {
+		return "."
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	rel, err := filepath.Rel(canonicalPath(root), canonicalPath(cwd))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "."
 	}
+	return rel
+}
 
-	return matches[selection-1].Text, nil
+// emitCDPath hands path to the shell integration to cd to. The wrapper
+// from "wt init" passes a file to write it to in WT_CD_FILE; without one,
+// it is printed as a WT_CD_PATH line for older wrappers to capture.
+func emitCDPath(path string) error {
+	if file := os.Getenv(shell.CDFileEnv); file != "" {
+		if err := os.WriteFile(file, []byte(path), 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", shell.CDFileEnv, err)
+		}
+		return nil
+	}
+	fmt.Printf("WT_CD_PATH=%s\n", path)
+	return nil
 }
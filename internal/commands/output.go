@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niczy/wt/internal/git"
+)
+
+// Format selects how commands write their results
+type Format int
+
+const (
+	// FormatText is human-readable output
+	FormatText Format = iota
+	// FormatJSON writes a single JSON object to stdout
+	FormatJSON
+	// FormatPorcelain writes stable "key value" lines, one record per
+	// worktree, with records separated by a blank line
+	FormatPorcelain
+)
+
+// worktreeInfo is the JSON representation of a worktree
+type worktreeInfo struct {
+	Name           string      `json:"name"`
+	Path           string      `json:"path"`
+	Repo           string      `json:"repo"`
+	Branch         string      `json:"branch"`
+	Head           string      `json:"head"`
+	Main           bool        `json:"main"`
+	Detached       bool        `json:"detached"`
+	Locked         bool        `json:"locked"`
+	LockReason     string      `json:"lock_reason,omitempty"`
+	Prunable       bool        `json:"prunable"`
+	PrunableReason string      `json:"prunable_reason,omitempty"`
+	Status         *statusInfo `json:"status,omitempty"`
+}
+
+// statusInfo is the JSON representation of a worktree's status
+type statusInfo struct {
+	Dirty      bool        `json:"dirty"`
+	Changed    int         `json:"changed"`
+	Untracked  int         `json:"untracked"`
+	Upstream   string      `json:"upstream,omitempty"`
+	Ahead      int         `json:"ahead"`
+	Behind     int         `json:"behind"`
+	LastCommit *commitInfo `json:"last_commit,omitempty"`
+}
+
+// commitInfo is the JSON representation of a commit
+type commitInfo struct {
+	Subject string    `json:"subject"`
+	Time    time.Time `json:"time"`
+}
+
+// errorInfo is the JSON representation of an error
+type errorInfo struct {
+	Code       string         `json:"code"`
+	Message    string         `json:"message"`
+	Candidates []worktreeInfo `json:"candidates,omitempty"`
+}
+
+func newWorktreeInfo(wt git.Worktree, status *git.Status) worktreeInfo {
+	info := worktreeInfo{
+		Name:           wt.Name,
+		Path:           wt.Path,
+		Repo:           wt.Repo,
+		Branch:         wt.Branch,
+		Head:           wt.Head,
+		Main:           wt.Main,
+		Detached:       wt.Detached,
+		Locked:         wt.Locked,
+		LockReason:     wt.LockReason,
+		Prunable:       wt.Prunable,
+		PrunableReason: wt.PrunableReason,
+	}
+	if status != nil {
+		info.Status = &statusInfo{
+			Dirty:     status.Dirty(),
+			Changed:   status.Changed,
+			Untracked: status.Untracked,
+			Upstream:  status.Upstream,
+			Ahead:     status.Ahead,
+			Behind:    status.Behind,
+		}
+		if status.Subject != "" {
+			info.Status.LastCommit = &commitInfo{Subject: status.Subject, Time: status.CommitTime}
+		}
+	}
+	return info
+}
+
+// writeJSON writes v to stdout as indented JSON
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeWorktree writes a single worktree result in a machine-readable format
+func writeWorktree(format Format, wt git.Worktree) error {
+	if format == FormatJSON {
+		return writeJSON(struct {
+			Worktree worktreeInfo `json:"worktree"`
+		}{newWorktreeInfo(wt, nil)})
+	}
+	writePorcelainRecord(os.Stdout, wt, nil)
+	return nil
+}
+
+// writeDeletedWorktree writes the result of a deletion in a
+// machine-readable format: the worktree, and the branch deleted with it
+// if any
+func writeDeletedWorktree(format Format, wt git.Worktree, deletedBranch string) error {
+	if format == FormatJSON {
+		return writeJSON(struct {
+			Worktree      worktreeInfo `json:"worktree"`
+			DeletedBranch string       `json:"deleted_branch,omitempty"`
+		}{newWorktreeInfo(wt, nil), deletedBranch})
+	}
+	writePorcelainFields(os.Stdout, wt, nil)
+	if deletedBranch != "" {
+		fmt.Printf("deleted-branch %s\n", deletedBranch)
+	}
+	fmt.Println()
+	return nil
+}
+
+// writeDeleteResults writes the outcome of deleting several worktrees in
+// a machine-readable format, with the error of each one that failed
+func writeDeleteResults(format Format, targets []*deleteTarget) error {
+	if format == FormatJSON {
+		type deletedInfo struct {
+			Worktree      worktreeInfo `json:"worktree"`
+			Deleted       bool         `json:"deleted"`
+			DeletedBranch string       `json:"deleted_branch,omitempty"`
+			Error         *errorInfo   `json:"error,omitempty"`
+		}
+		infos := make([]deletedInfo, 0, len(targets))
+		for _, t := range targets {
+			info := deletedInfo{Worktree: newWorktreeInfo(t.wt, nil), Deleted: t.deleted, DeletedBranch: t.deletedBranch}
+			if t.err != nil {
+				info.Error = &errorInfo{Code: ErrorCode(t.err), Message: t.err.Error()}
+			}
+			infos = append(infos, info)
+		}
+		return writeJSON(struct {
+			Worktrees []deletedInfo `json:"worktrees"`
+		}{infos})
+	}
+
+	for _, t := range targets {
+		writePorcelainFields(os.Stdout, t.wt, nil)
+		if t.deleted {
+			fmt.Println("deleted")
+		}
+		if t.deletedBranch != "" {
+			fmt.Printf("deleted-branch %s\n", t.deletedBranch)
+		}
+		if t.err != nil {
+			fmt.Printf("error %s %s\n", ErrorCode(t.err), oneLine(t.err.Error()))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// writePorcelainRecord writes one worktree as porcelain lines followed by
+// a blank line. Empty and false fields are omitted.
+func writePorcelainRecord(w io.Writer, wt git.Worktree, status *git.Status) {
+	writePorcelainFields(w, wt, status)
+	fmt.Fprintln(w)
+}
+
+// writePorcelainFields writes the lines of a porcelain record for wt
+func writePorcelainFields(w io.Writer, wt git.Worktree, status *git.Status) {
+	fmt.Fprintf(w, "worktree %s\n", wt.Path)
+	fmt.Fprintf(w, "name %s\n", wt.Name)
+	fmt.Fprintf(w, "repo %s\n", wt.Repo)
+	if wt.Head != "" {
+		fmt.Fprintf(w, "head %s\n", wt.Head)
+	}
+	if wt.Branch != "" {
+		fmt.Fprintf(w, "branch %s\n", wt.Branch)
+	}
+	if wt.Main {
+		fmt.Fprintln(w, "main")
+	}
+	if wt.Detached {
+		fmt.Fprintln(w, "detached")
+	}
+	if wt.Locked {
+		fmt.Fprintln(w, strings.TrimRight("locked "+oneLine(wt.LockReason), " "))
+	}
+	if wt.Prunable {
+		fmt.Fprintln(w, strings.TrimRight("prunable "+oneLine(wt.PrunableReason), " "))
+	}
+	if status != nil {
+		fmt.Fprintf(w, "changed %d\n", status.Changed)
+		fmt.Fprintf(w, "untracked %d\n", status.Untracked)
+		if status.Upstream != "" {
+			fmt.Fprintf(w, "upstream %s\n", status.Upstream)
+			fmt.Fprintf(w, "ahead %d\n", status.Ahead)
+			fmt.Fprintf(w, "behind %d\n", status.Behind)
+		}
+		if status.Subject != "" {
+			fmt.Fprintf(w, "commit-time %s\n", strconv.FormatInt(status.CommitTime.Unix(), 10))
+			fmt.Fprintf(w, "commit-subject %s\n", oneLine(status.Subject))
+		}
+	}
+}
+
+// oneLine collapses the whitespace in s, including line breaks in git's
+// messages, so it fits on a single porcelain line
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ReportError writes err to stdout in the given machine-readable format,
+// or to stderr as "Error: ..." for text output and for errors the
+// command's output already describes
+func ReportError(format Format, err error) {
+	var coded *Error
+	var candidates []git.Worktree
+	if errors.As(err, &coded) {
+		if coded.Reported {
+			format = FormatText
+		}
+		candidates = coded.Candidates
+	}
+	switch format {
+	case FormatJSON:
+		info := errorInfo{Code: ErrorCode(err), Message: err.Error()}
+		for _, wt := range candidates {
+			info.Candidates = append(info.Candidates, newWorktreeInfo(wt, nil))
+		}
+		_ = writeJSON(struct {
+			Error errorInfo `json:"error"`
+		}{info})
+	case FormatPorcelain:
+		fmt.Printf("error %s %s\n", ErrorCode(err), oneLine(err.Error()))
+		for _, wt := range candidates {
+			fmt.Printf("candidate %s\n", wt.Path)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		for _, wt := range candidates {
+			fmt.Fprintf(os.Stderr, "  %s\n", worktreeLabel(wt))
+		}
+	}
+}
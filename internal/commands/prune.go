@@ -0,0 +1,344 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/git"
+)
+
+// PruneOptions holds optional settings for Prune
+type PruneOptions struct {
+	// Format selects the output format
+	Format Format
+	// Base overrides the base_ref setting, the ref branches must be merged
+	// into to be pruned
+	Base string
+	// StaleDays also prunes worktrees without a commit or visit in this
+	// many days; 0 leaves them alone
+	StaleDays int
+	// DryRun lists what would be pruned without deleting anything
+	DryRun bool
+	// Yes deletes without asking for confirmation
+	Yes bool
+	// Force prunes worktrees despite unsaved work
+	Force bool
+	// DeleteBranch also deletes the branches merged into the base,
+	// overriding the delete_branch setting
+	DeleteBranch bool
+	// NoInput never prompts, like the noninteractive setting: nothing is
+	// deleted without Yes
+	NoInput bool
+}
+
+// pruneCandidate is a worktree Prune found a reason to delete
+type pruneCandidate struct {
+	deleteTarget
+	// reasons say why it is pruned, e.g. "merged into main"
+	reasons []string
+	// skip says why it is kept anyway, e.g. unsaved work
+	skip string
+}
+
+// Prune handles "wt prune" to delete the worktrees whose branch is merged
+// into the base or whose upstream is gone, those whose directory is
+// missing, and optionally those left untouched for a while. It applies the
+// same safety checks as Delete and asks once for all of them. The git
+// metadata of each missing worktree it deletes is removed as well.
+func Prune(opts PruneOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if opts.Base != "" {
+		if err := cfg.Set(config.KeyBaseRef, opts.Base, config.SourceFlag+" --base"); err != nil {
+			return withCode(CodeInvalidInput, err)
+		}
+	}
+	if opts.DeleteBranch {
+		if err := cfg.Set(config.KeyDeleteBranch, "true", config.SourceFlag+" --delete-branch"); err != nil {
+			return withCode(CodeInvalidInput, err)
+		}
+	}
+	if err := setNoInput(cfg, opts.NoInput); err != nil {
+		return err
+	}
+	if opts.StaleDays < 0 {
+		return errorf(CodeInvalidInput, "--stale must be a number of days, got %d", opts.StaleDays)
+	}
+
+	worktrees, err := listWorktrees(cfg)
+	if err != nil {
+		return err
+	}
+
+	candidates := findPruneCandidates(cfg, worktrees, opts.StaleDays, time.Now())
+	pruning := 0
+	branches := 0
+	for i := range candidates {
+		c := &candidates[i]
+		if c.skip != "" {
+			continue
+		}
+		clean, err := checkUnsavedWork(c.wt, opts.Force)
+		if err != nil {
+			c.skip = err.Error()
+			continue
+		}
+		c.clean = clean
+		c.plan = planBranch(cfg, c.wt, false)
+		pruning++
+		if c.plan.delete {
+			branches++
+		}
+	}
+
+	if opts.Format == FormatText {
+		if err := writePruneCandidates(candidates); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case opts.DryRun:
+		if opts.Format == FormatText && pruning > 0 {
+			fmt.Println("Dry run: nothing was deleted")
+		}
+		return writePruneResult(opts, candidates)
+	case pruning > 0 && !opts.Yes && cfg.Bool(config.KeyNonInteractive):
+		return errorf(CodeNeedsYes, "not deleting %s without --yes since prompts are disabled", countOf(pruning, "worktree", "worktrees"))
+	case pruning > 0 && !opts.Yes && cfg.Bool(config.KeyConfirmDelete):
+		question := "Delete " + countOf(pruning, "worktree", "worktrees")
+		if branches > 0 {
+			question += " and " + countOf(branches, "merged branch", "merged branches")
+		}
+		ok, err := confirm(question + "?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errorf(CodeCancelled, "prune cancelled")
+		}
+	}
+
+	var targets []*deleteTarget
+	for i := range candidates {
+		if candidates[i].skip == "" {
+			targets = append(targets, &candidates[i].deleteTarget)
+		}
+	}
+	removeTargets(cfg, targets, opts.Force)
+
+	failed := 0
+	for i := range candidates {
+		c := &candidates[i]
+		if c.skip != "" {
+			continue
+		}
+		if c.err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to delete '%s': %v\n", c.wt.Name, c.err)
+			c.skip = c.err.Error()
+			failed++
+			continue
+		}
+		if opts.Format == FormatText {
+			fmt.Printf("Deleted worktree: %s\n", c.wt.Name)
+			if c.deletedBranch != "" {
+				fmt.Printf("Deleted branch: %s\n", c.deletedBranch)
+			}
+		}
+		if err := runHook(cfg, config.KeyHookPostDelete, "", c.wt); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if err := writePruneResult(opts, candidates); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return reported(errorf(CodeGit, "failed to delete %s", countOf(failed, "worktree", "worktrees")))
+	}
+	return nil
+}
+
+// pruneRepo holds what Prune needs to know about a repository
+type pruneRepo struct {
+	// base is the ref branches must be merged into, empty if there is none
+	base string
+	// gone holds the branches whose upstream was deleted
+	gone map[string]bool
+}
+
+// findPruneCandidates returns the worktrees other than main ones that are
+// merged into the base, have lost their upstream, have missing directories
+// or, if staleDays is positive, have had no commit or visit in that many
+// days. Locked ones are returned as skipped.
+func findPruneCandidates(cfg *config.Config, worktrees []git.Worktree, staleDays int, now time.Time) []pruneCandidate {
+	repos := map[string]*pruneRepo{}
+	repoInfo := func(path string) *pruneRepo {
+		if r, ok := repos[path]; ok {
+			return r
+		}
+		r := &pruneRepo{}
+		repos[path] = r
+		if base, err := baseRef(cfg, path); err == nil {
+			if _, err := git.ResolveCommit(path, base); err == nil {
+				r.base = base
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: %v in %s\n", err, path)
+			}
+		}
+		gone, err := git.GoneBranches(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		r.gone = gone
+		return r
+	}
+
+	var statuses []git.Status
+	var errs []error
+	lastVisits := map[string]time.Time{}
+	if staleDays > 0 {
+		statuses, errs = worktreeStatuses(worktrees)
+		for _, e := range loadHistory(cfg).Entries() {
+			lastVisits[e.Path] = e.LastVisit()
+		}
+	}
+
+	var candidates []pruneCandidate
+	for i, wt := range worktrees {
+		if wt.Main {
+			continue
+		}
+		var reasons []string
+		if wt.Prunable {
+			reasons = append(reasons, "directory missing")
+		} else if wt.Branch != "" {
+			r := repoInfo(wt.Repo)
+			if r.base != "" && wt.Branch != r.base && pruneMerged(wt, r.base) {
+				reasons = append(reasons, "merged into "+r.base)
+			}
+			if r.gone[wt.Branch] {
+				reasons = append(reasons, "upstream gone")
+			}
+		}
+		if staleDays > 0 && !wt.Prunable && errs[i] == nil {
+			last := statuses[i].CommitTime
+			if visit := lastVisits[wt.Path]; visit.After(last) {
+				last = visit
+			}
+			if age := now.Sub(last); !last.IsZero() && age >= time.Duration(staleDays)*24*time.Hour {
+				reasons = append(reasons, fmt.Sprintf("untouched for %d days", int(age.Hours()/24)))
+			}
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		c := pruneCandidate{deleteTarget: deleteTarget{wt: wt}, reasons: reasons}
+		if wt.Locked {
+			c.skip = "locked"
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// pruneMerged reports whether the branch of wt is merged into base. A
+// branch nothing was committed to yet is trivially merged, but most likely
+// new rather than done with, so it doesn't count.
+func pruneMerged(wt git.Worktree, base string) bool {
+	merged, err := git.IsMerged(wt.Repo, wt.Branch, base)
+	if err == nil && merged {
+		merged, err = git.BranchMoved(wt.Repo, wt.Branch)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return false
+	}
+	return merged
+}
+
+// writePruneCandidates lists the worktrees to prune and the ones kept
+// despite a reason to prune them
+func writePruneCandidates(candidates []pruneCandidate) error {
+	var pruning, skipped []pruneCandidate
+	for _, c := range candidates {
+		if c.skip == "" {
+			pruning = append(pruning, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	if len(pruning) == 0 {
+		fmt.Println("Nothing to prune")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if len(pruning) > 0 {
+		fmt.Fprintln(w, "Worktrees to prune:")
+		for _, c := range pruning {
+			reasons := strings.Join(c.reasons, ", ")
+			if c.plan.delete {
+				reasons += " (deleting branch)"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", c.wt.Name, orDash(c.wt.Branch), reasons)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintln(w, "Keeping:")
+		for _, c := range skipped {
+			fmt.Fprintf(w, "  %s\t%s\t%s: %s\n", c.wt.Name, orDash(c.wt.Branch), strings.Join(c.reasons, ", "), c.skip)
+		}
+	}
+	return w.Flush()
+}
+
+// writePruneResult writes the worktrees Prune considered in a
+// machine-readable format, with why each was pruned or kept
+func writePruneResult(opts PruneOptions, candidates []pruneCandidate) error {
+	if opts.Format == FormatText {
+		return nil
+	}
+
+	if opts.Format == FormatJSON {
+		type prunedInfo struct {
+			Worktree      worktreeInfo `json:"worktree"`
+			Reasons       []string     `json:"reasons"`
+			Skipped       string       `json:"skipped,omitempty"`
+			Deleted       bool         `json:"deleted"`
+			DeletedBranch string       `json:"deleted_branch,omitempty"`
+		}
+		infos := make([]prunedInfo, 0, len(candidates))
+		for _, c := range candidates {
+			infos = append(infos, prunedInfo{newWorktreeInfo(c.wt, nil), c.reasons, c.skip, c.deleted, c.deletedBranch})
+		}
+		return writeJSON(struct {
+			DryRun    bool         `json:"dry_run"`
+			Worktrees []prunedInfo `json:"worktrees"`
+		}{opts.DryRun, infos})
+	}
+
+	for _, c := range candidates {
+		writePorcelainFields(os.Stdout, c.wt, nil)
+		for _, reason := range c.reasons {
+			fmt.Printf("prune-reason %s\n", reason)
+		}
+		if c.skip != "" {
+			fmt.Printf("skipped %s\n", oneLine(c.skip))
+		}
+		if c.deleted {
+			fmt.Println("deleted")
+		}
+		if c.deletedBranch != "" {
+			fmt.Printf("deleted-branch %s\n", c.deletedBranch)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/fuzzy"
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/picker"
+)
+
+// previewCommits is the number of recent commits shown in the picker preview
+const previewCommits = 10
+
+// selectWorktree asks the user to choose one of several matches for q. On
+// a terminal it shows the full-screen picker; otherwise, or if the picker
+// can't start, it falls back to a numbered prompt. purpose completes the
+// prompt, e.g. "to delete", and may be empty.
+func selectWorktree(cfg *config.Config, q fuzzy.Query, matches []git.Worktree, purpose string) (git.Worktree, error) {
+	selected, err := chooseWorktrees(cfg, q, matches, purpose, false)
+	if err != nil {
+		return git.Worktree{}, err
+	}
+	return selected[0], nil
+}
+
+// selectWorktrees is selectWorktree for choosing one or more matches:
+// marked with Tab in the picker, or as a list such as "1,3-5" at the
+// numbered prompt
+func selectWorktrees(cfg *config.Config, q fuzzy.Query, matches []git.Worktree, purpose string) ([]git.Worktree, error) {
+	return chooseWorktrees(cfg, q, matches, purpose, true)
+}
+
+// chooseWorktrees implements selectWorktree and selectWorktrees. When
+// prompts are disabled, it fails listing the matches instead.
+func chooseWorktrees(cfg *config.Config, q fuzzy.Query, matches []git.Worktree, purpose string, multi bool) ([]git.Worktree, error) {
+	if cfg.Bool(config.KeyNonInteractive) {
+		return nil, &Error{
+			Code:       CodeAmbiguous,
+			Err:        fmt.Errorf("%d worktrees match and prompts are disabled; narrow the query", len(matches)),
+			Candidates: matches,
+		}
+	}
+	color := useColor(cfg, os.Stderr)
+	if picker.Available() {
+		labels := make([]string, len(matches))
+		for i, wt := range matches {
+			labels[i] = worktreeLabel(wt)
+		}
+		indices, err := picker.RunMulti(picker.Options{
+			Items:   labels,
+			Prompt:  strings.TrimSpace("Select worktree "+purpose) + "> ",
+			Preview: func(i int) string { return worktreePreview(matches[i]) },
+			Color:   color,
+			Literal: q.Literal,
+			Multi:   multi,
+		})
+		switch {
+		case err == nil:
+			selected := make([]git.Worktree, len(indices))
+			for i, index := range indices {
+				selected[i] = matches[index]
+			}
+			return selected, nil
+		case errors.Is(err, picker.ErrCancelled):
+			return nil, errorf(CodeCancelled, "selection cancelled")
+		case !errors.Is(err, picker.ErrUnavailable):
+			return nil, err
+		}
+	}
+	return promptSelection(q, matches, purpose, color, multi)
+}
+
+// promptSelection prompts the user to select from multiple matches by
+// number, highlighting the characters matched by q if color is set. With
+// multi, several numbers and ranges may be given, e.g. "1,3-5".
+func promptSelection(q fuzzy.Query, matches []git.Worktree, purpose string, color, multi bool) ([]git.Worktree, error) {
+	fmt.Fprintf(os.Stderr, "Multiple matches found:\n")
+	for i, match := range matches {
+		label := worktreeLabel(match)
+		if color {
+			label = highlightLabel(q, match)
+		}
+		fmt.Fprintf(os.Stderr, "  [%d] %s\n", i+1, label)
+	}
+	if purpose != "" {
+		purpose = " " + purpose
+	}
+	if multi {
+		fmt.Fprintf(os.Stderr, "Enter selection%s (1-%d, e.g. 1,3-5): ", purpose, len(matches))
+	} else {
+		fmt.Fprintf(os.Stderr, "Enter selection%s (1-%d): ", purpose, len(matches))
+	}
+
+	input, err := readLine()
+	if err != nil {
+		return nil, errorf(CodeInvalidInput, "failed to read input: %w", err)
+	}
+
+	input = strings.TrimSpace(input)
+	var indices []int
+	if multi {
+		indices, err = parseSelection(input, len(matches))
+	} else {
+		var selection int
+		selection, err = strconv.Atoi(input)
+		if err == nil && (selection < 1 || selection > len(matches)) {
+			err = fmt.Errorf("out of range")
+		}
+		indices = []int{selection - 1}
+	}
+	if err != nil {
+		return nil, errorf(CodeInvalidInput, "invalid selection: %s", input)
+	}
+
+	selected := make([]git.Worktree, len(indices))
+	for i, index := range indices {
+		selected[i] = matches[index]
+	}
+	return selected, nil
+}
+
+// setNoInput applies the --no-input flag, which disables prompts like the
+// noninteractive setting
+func setNoInput(cfg *config.Config, noInput bool) error {
+	if !noInput {
+		return nil
+	}
+	if err := cfg.Set(config.KeyNonInteractive, "true", config.SourceFlag+" --no-input"); err != nil {
+		return withCode(CodeInvalidInput, err)
+	}
+	return nil
+}
+
+// stdin buffers os.Stdin for readLine, so that answers piped to several
+// prompts in a row each reach their prompt
+var stdin struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// readLine reads a line of input from stdin
+func readLine() (string, error) {
+	if stdin.file != os.Stdin {
+		stdin.file = os.Stdin
+		stdin.reader = bufio.NewReader(os.Stdin)
+	}
+	return stdin.reader.ReadString('\n')
+}
+
+// parseSelection parses a list of numbers and ranges from 1 to n, such as
+// "1,3-5" or "2 4", into zero-based indices in the order given, without
+// duplicates
+func parseSelection(input string, n int) ([]int, error) {
+	fields := strings.FieldsFunc(input, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty selection")
+	}
+	var indices []int
+	seen := map[int]bool{}
+	for _, field := range fields {
+		first, last, isRange := strings.Cut(field, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil {
+				return nil, err
+			}
+		}
+		if from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("%s is out of range", field)
+		}
+		for i := from; i <= to; i++ {
+			if !seen[i] {
+				seen[i] = true
+				indices = append(indices, i-1)
+			}
+		}
+	}
+	return indices, nil
+}
+
+// worktreePreview describes a worktree for the picker's preview pane:
+// its path, branch, status and recent commits
+func worktreePreview(wt git.Worktree) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Path:    %s\n", wt.Path)
+	if wt.Detached {
+		fmt.Fprintf(&b, "Branch:  (detached at %s)\n", shortHash(wt.Head))
+	} else {
+		fmt.Fprintf(&b, "Branch:  %s\n", orDash(wt.Branch))
+	}
+
+	status, err := git.GetStatus(wt.Path)
+	if err != nil {
+		fmt.Fprintf(&b, "Status:  unknown (%v)\n", err)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Status:  %s\n", describeChanges(status))
+	if upstream := describeUpstream(status); upstream != "" {
+		fmt.Fprintf(&b, "Remote:  %s (%s)\n", status.Upstream, upstream)
+	}
+
+	commits, err := git.RecentCommits(wt.Path, previewCommits)
+	if err == nil && len(commits) > 0 {
+		b.WriteString("\nRecent commits:\n")
+		for _, commit := range commits {
+			fmt.Fprintf(&b, "  %s\n", commit)
+		}
+	}
+	return b.String()
+}
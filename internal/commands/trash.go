@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/git"
+	"github.com/niczy/wt/internal/trash"
+)
+
+// TrashOptions holds optional settings for TrashList and Undo
+type TrashOptions struct {
+	// Format selects the output format
+	Format Format
+}
+
+// trashInfo is the JSON representation of a deleted worktree in the trash
+type trashInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Repo      string    `json:"repo"`
+	Branch    string    `json:"branch,omitempty"`
+	Head      string    `json:"head"`
+	Deleted   time.Time `json:"deleted"`
+	Expires   time.Time `json:"expires"`
+	Changes   bool      `json:"changes"`
+	Untracked int       `json:"untracked"`
+}
+
+// TrashList handles "wt trash ls" to list the deleted worktrees that
+// "wt undo" can restore
+func TrashList(opts TrashOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	entries, err := expireTrash(cfg)
+	if err != nil {
+		return err
+	}
+	days := cfg.Days(config.KeyTrashDays)
+	expires := func(e trash.Entry) time.Time {
+		return e.Deleted.Add(time.Duration(days) * 24 * time.Hour)
+	}
+
+	switch opts.Format {
+	case FormatJSON:
+		infos := make([]trashInfo, 0, len(entries))
+		for _, e := range entries {
+			infos = append(infos, trashInfo{e.ID, e.Name, e.Path, e.Repo, e.Branch, e.Head, e.Deleted, expires(e), e.Changes, e.Untracked})
+		}
+		return writeJSON(struct {
+			Trash []trashInfo `json:"trash"`
+		}{infos})
+	case FormatPorcelain:
+		for _, e := range entries {
+			fmt.Printf("id %s\n", e.ID)
+			fmt.Printf("name %s\n", e.Name)
+			fmt.Printf("worktree %s\n", e.Path)
+			fmt.Printf("repo %s\n", e.Repo)
+			fmt.Printf("head %s\n", e.Head)
+			if e.Branch != "" {
+				fmt.Printf("branch %s\n", e.Branch)
+			}
+			fmt.Printf("deleted %s\n", e.Deleted.UTC().Format(time.RFC3339))
+			fmt.Printf("expires %s\n", expires(e).UTC().Format(time.RFC3339))
+			if e.Changes {
+				fmt.Println("changes")
+			}
+			fmt.Printf("untracked %d\n", e.Untracked)
+			fmt.Println()
+		}
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("The trash is empty")
+		return nil
+	}
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBRANCH\tDELETED\tSAVED\tID")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Name, orDash(e.Branch), formatAge(now.Sub(e.Deleted)), describeSaved(e), e.ID)
+	}
+	return w.Flush()
+}
+
+// Undo handles "wt undo" to restore a deleted worktree from the trash: the
+// most recently deleted one, or the latest one named name (or with that
+// ID). It recreates the worktree at its old path on its branch, or on the
+// saved commit if the branch is gone, then reapplies the saved changes.
+func Undo(name string, opts TrashOptions) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	entries, err := expireTrash(cfg)
+	if err != nil {
+		return err
+	}
+
+	var entry *trash.Entry
+	for i, e := range entries {
+		if name == "" || e.Name == name || e.ID == name {
+			entry = &entries[i]
+			break
+		}
+	}
+	switch {
+	case entry == nil && name == "":
+		return errorf(CodeNoMatch, "the trash is empty")
+	case entry == nil:
+		return errorf(CodeNoMatch, "no deleted worktree named '%s' in the trash", name)
+	}
+	e := *entry
+
+	if _, err := os.Stat(e.Path); err == nil {
+		return errorf(CodeExists, "cannot restore '%s': %s already exists", e.Name, e.Path)
+	}
+	if e.Branch != "" {
+		if tip, err := git.ResolveCommit(e.Repo, "refs/heads/"+e.Branch); err == nil && tip != e.Head {
+			fmt.Fprintf(os.Stderr, "Warning: branch '%s' has moved since '%s' was deleted; its saved changes are applied to the new tip\n", e.Branch, e.Name)
+		}
+	}
+	if err := git.AddWorktree(e.Repo, e.Path, e.Branch, e.Ref()); err != nil {
+		return withCode(CodeGit, err)
+	}
+
+	// Whatever can't be restored stays in the trash for another try
+	restored := true
+	if patch := e.PatchPath(); patch != "" {
+		if err := git.ApplyPatch(e.Path, patch); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			restored = false
+		}
+	}
+	if err := e.RestoreUntracked(e.Path); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		restored = false
+	}
+	if restored {
+		dropTrash(cfg, e)
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: '%s' stays in the trash as %s\n", e.Name, e.ID)
+	}
+
+	wt, ok := lookupWorktree(e.Path, e.Name)
+	if !ok {
+		wt = git.Worktree{Name: e.Name, Path: e.Path, Repo: e.Repo, Branch: e.Branch, Head: e.Head}
+	}
+	if opts.Format != FormatText {
+		return writeWorktree(opts.Format, wt)
+	}
+	fmt.Printf("Restored worktree: %s\n", e.Name)
+	recordVisit(loadHistory(cfg), wt)
+	return emitCDPath(e.Path)
+}
+
+// trashWorktree saves what deleting wt would lose to the trash: its commit
+// under a trash ref, its changes to tracked files and its untracked files
+func trashWorktree(cfg *config.Config, wt git.Worktree) (trash.Entry, error) {
+	patch, err := git.DiffHead(wt.Path)
+	if err != nil {
+		return trash.Entry{}, err
+	}
+	files, err := git.UntrackedFiles(wt.Path)
+	if err != nil {
+		return trash.Entry{}, err
+	}
+	// Nested repositories are too big to keep around
+	var untracked []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "/") {
+			fmt.Fprintf(os.Stderr, "Warning: not saving nested repository %s to the trash\n", file)
+			continue
+		}
+		untracked = append(untracked, file)
+	}
+
+	t := trash.Open(cfg.Get(config.KeyDataDir))
+	e := trash.Entry{Name: wt.Name, Path: wt.Path, Repo: wt.Repo, Branch: wt.Branch, Head: wt.Head}
+	e, err = t.Add(e, patch, wt.Path, untracked, time.Now())
+	if err != nil {
+		return e, err
+	}
+	if err := git.UpdateRef(wt.Repo, e.Ref(), wt.Head); err != nil {
+		t.Remove(e)
+		return e, err
+	}
+	return e, nil
+}
+
+// dropTrash permanently deletes an entry from the trash along with the
+// ref keeping its commit
+func dropTrash(cfg *config.Config, e trash.Entry) {
+	// The repository may be gone too, taking the ref with it
+	if _, err := os.Stat(e.Repo); err == nil {
+		if err := git.DeleteRef(e.Repo, e.Ref()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if err := trash.Open(cfg.Get(config.KeyDataDir)).Remove(e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// expireTrash permanently deletes the worktrees that have been in the
+// trash longer than trash_days, and returns the remaining ones
+func expireTrash(cfg *config.Config) ([]trash.Entry, error) {
+	entries, err := trash.Open(cfg.Get(config.KeyDataDir)).List()
+	if err != nil {
+		return nil, err
+	}
+
+	days := cfg.Days(config.KeyTrashDays)
+	now := time.Now()
+	var kept []trash.Entry
+	for _, e := range entries {
+		if !e.Expired(days, now) {
+			kept = append(kept, e)
+			continue
+		}
+		dropTrash(cfg, e)
+	}
+	return kept, nil
+}
+
+// describeSaved summarizes what an entry saved besides its commit, e.g.
+// "changes, 2 untracked files"
+func describeSaved(e trash.Entry) string {
+	var parts []string
+	if e.Changes {
+		parts = append(parts, "changes")
+	}
+	if e.Untracked > 0 {
+		parts = append(parts, countOf(e.Untracked, "untracked file", "untracked files"))
+	}
+	if len(parts) == 0 {
+		return "commit only"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/niczy/wt/internal/config"
+	"github.com/niczy/wt/internal/git"
+)
+
+// listWorktrees returns the worktrees found in the layout described by the
+// path_template config value
+func listWorktrees(cfg *config.Config) ([]git.Worktree, error) {
+	vars := templateVars(cfg)
+	if root, err := git.GetRepoRoot(); err == nil {
+		vars.RepoParent = filepath.Dir(root)
+	}
+
+	pattern, root, err := config.PathTemplateGlob(cfg.Get(config.KeyPathTemplate), vars)
+	if err != nil {
+		return nil, withCode(CodeConfig, err)
+	}
+	if pattern == "" {
+		// The layout can't be resolved from here, e.g. {repo_parent}
+		// outside a repository
+		return nil, nil
+	}
+	// A layout with one directory per worktree right under WT_HOME, like
+	// the default, is searched as a whole, as wt always did: older
+	// versions and users may have put worktrees there that the template
+	// wouldn't produce, such as names without a dash
+	if flat, _, err := config.PathTemplateGlob("{name}", vars); err == nil && filepath.Dir(flat) == filepath.Dir(pattern) {
+		pattern = flat
+	}
+	worktrees, err := git.ListWorktreesGlob(root, pattern)
+	if err != nil {
+		return nil, withCode(CodeGit, err)
+	}
+	return worktrees, nil
+}
+
+// lookupWorktree describes the worktree at path, named name, as git
+// reports it. It returns false if git doesn't know path as a worktree.
+func lookupWorktree(path, name string) (git.Worktree, bool) {
+	worktrees, err := git.ListRepoWorktrees(path)
+	if err != nil {
+		return git.Worktree{}, false
+	}
+	for _, wt := range worktrees {
+		if wt.Path == path {
+			wt.Name = name
+			return wt, true
+		}
+	}
+	return git.Worktree{}, false
+}
+
+// templateVars returns the path_template values that don't depend on the
+// worktree being created
+func templateVars(cfg *config.Config) config.TemplateVars {
+	return config.TemplateVars{
+		WTHome: cfg.Get(config.KeyWTHome),
+		User:   currentUser(),
+		Date:   time.Now().Format("2006-01-02"),
+	}
+}
+
+// currentUser returns the current user's login name
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return filepath.Base(u.Username)
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// filterWorktrees returns the worktrees for which keep returns true
+func filterWorktrees(worktrees []git.Worktree, keep func(git.Worktree) bool) []git.Worktree {
+	var result []git.Worktree
+	for _, wt := range worktrees {
+		if keep(wt) {
+			result = append(result, wt)
+		}
+	}
+	return result
+}
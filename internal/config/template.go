@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TemplateVars holds the values substituted into path_template placeholders
+type TemplateVars struct {
+	WTHome     string // {wt_home}
+	Repo       string // {repo}: repository directory name
+	RepoParent string // {repo_parent}: directory containing the repository
+	Name       string // {name}: worktree name slug
+	Branch     string // {branch}: branch name slug
+	User       string // {user}: current user name
+	Date       string // {date}: creation date, YYYY-MM-DD
+}
+
+var placeholderRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+func (v TemplateVars) lookup(placeholder string) (string, bool) {
+	switch placeholder {
+	case "{wt_home}":
+		return v.WTHome, true
+	case "{repo}":
+		return v.Repo, true
+	case "{repo_parent}":
+		return v.RepoParent, true
+	case "{name}":
+		return v.Name, true
+	case "{branch}":
+		return v.Branch, true
+	case "{user}":
+		return v.User, true
+	case "{date}":
+		return v.Date, true
+	}
+	return "", false
+}
+
+// ExpandPathTemplate returns the worktree path produced by tmpl.
+// Relative results are placed under vars.WTHome.
+func ExpandPathTemplate(tmpl string, vars TemplateVars) (string, error) {
+	var badPlaceholder string
+	path := placeholderRe.ReplaceAllStringFunc(tmpl, func(p string) string {
+		value, ok := vars.lookup(p)
+		if !ok && badPlaceholder == "" {
+			badPlaceholder = p
+		}
+		return value
+	})
+	if badPlaceholder != "" {
+		return "", fmt.Errorf("unknown placeholder %s in path_template '%s'", badPlaceholder, tmpl)
+	}
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("path_template '%s' produced an empty path", tmpl)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(vars.WTHome, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// wildcard stands in for a per-worktree placeholder until the literal
+// parts of a glob pattern are escaped
+const wildcard = "\x00"
+
+// PathTemplateGlob returns a filepath.Glob pattern matching every path tmpl
+// can produce, along with the fixed directory the pattern is rooted at.
+// Per-worktree placeholders ({repo}, {name}, {branch}, {date}) become
+// wildcards; the others are taken from vars, and like the rest of the
+// template match literally even if they contain glob syntax. It returns an
+// empty pattern if the layout depends on a value that is unknown, such as
+// {repo_parent} outside a repository.
+func PathTemplateGlob(tmpl string, vars TemplateVars) (pattern, root string, err error) {
+	var badPlaceholder string
+	missing := false
+	path := placeholderRe.ReplaceAllStringFunc(tmpl, func(p string) string {
+		switch p {
+		case "{repo}", "{name}", "{branch}", "{date}":
+			return wildcard
+		}
+		value, ok := vars.lookup(p)
+		if !ok && badPlaceholder == "" {
+			badPlaceholder = p
+		}
+		if value == "" {
+			missing = true
+		}
+		return value
+	})
+	if badPlaceholder != "" {
+		return "", "", fmt.Errorf("unknown placeholder %s in path_template '%s'", badPlaceholder, tmpl)
+	}
+	if missing {
+		return "", "", nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(vars.WTHome, path)
+	}
+	path = filepath.Clean(path)
+
+	// The root is the longest leading run of components without wildcards
+	root = path
+	for strings.Contains(root, wildcard) {
+		root = filepath.Dir(root)
+	}
+
+	parts := strings.Split(path, wildcard)
+	for i, part := range parts {
+		parts[i] = escapeGlob(part)
+	}
+	return strings.Join(parts, "*"), root, nil
+}
+
+// escapeGlob escapes the characters filepath.Match treats as syntax in s.
+// Character classes work on every platform, unlike backslash escapes.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '*' || r == '?' || r == '[':
+			b.WriteString("[" + string(r) + "]")
+		case r == '\\' && filepath.Separator != '\\':
+			b.WriteString(`\\`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
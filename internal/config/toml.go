@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML used by wt config files: comments,
+// [table] headers and key = value pairs whose values are strings, booleans
+// or integers. Keys inside a table are returned as "table.key".
+func parseTOML(r io.Reader, name string) (map[string]string, error) {
+	values := make(map[string]string)
+	table := ""
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || strings.TrimSpace(stripComment(line[end+1:])) != "" {
+				return nil, fmt.Errorf("%s:%d: invalid table header", name, lineNum)
+			}
+			table = strings.TrimSpace(line[1:end])
+			if table == "" {
+				return nil, fmt.Errorf("%s:%d: empty table name", name, lineNum)
+			}
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", name, lineNum)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: missing key", name, lineNum)
+		}
+		value, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, lineNum, err)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate key '%s'", name, lineNum, key)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	return values, nil
+}
+
+// parseValue parses a single TOML value and returns it as a string
+func parseValue(raw string) (string, error) {
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+
+	switch raw[0] {
+	case '"':
+		// Basic string: find the closing quote, skipping escaped characters
+		for i := 1; i < len(raw); i++ {
+			switch raw[i] {
+			case '\\':
+				i++
+			case '"':
+				if strings.TrimSpace(stripComment(raw[i+1:])) != "" {
+					return "", fmt.Errorf("unexpected text after string")
+				}
+				value, err := strconv.Unquote(raw[:i+1])
+				if err != nil {
+					return "", fmt.Errorf("invalid string %s", raw[:i+1])
+				}
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("unterminated string")
+	case '\'':
+		// Literal string: no escapes
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		if strings.TrimSpace(stripComment(raw[end+2:])) != "" {
+			return "", fmt.Errorf("unexpected text after string")
+		}
+		return raw[1 : end+1], nil
+	}
+
+	value := strings.TrimSpace(stripComment(raw))
+	if value == "true" || value == "false" {
+		return value, nil
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return value, nil
+	}
+	return "", fmt.Errorf("unsupported value %s (strings must be quoted)", value)
+}
+
+// stripComment removes a trailing # comment from an unquoted fragment
+func stripComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
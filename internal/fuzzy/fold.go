@@ -0,0 +1,81 @@
+package fuzzy
+
+import "unicode"
+
+// diacritics maps accented Latin letters to their base letter
+var diacritics = map[rune]rune{}
+
+func init() {
+	for base, accented := range map[rune]string{
+		'A': "ÀÁÂÃÄÅĀĂĄǍ", 'a': "àáâãäåāăąǎ",
+		'C': "ÇĆĈĊČ", 'c': "çćĉċč",
+		'D': "ĎĐ", 'd': "ďđ",
+		'E': "ÈÉÊËĒĔĖĘĚ", 'e': "èéêëēĕėęě",
+		'G': "ĜĞĠĢ", 'g': "ĝğġģ",
+		'H': "ĤĦ", 'h': "ĥħ",
+		'I': "ÌÍÎÏĨĪĬĮİǏ", 'i': "ìíîïĩīĭįıǐ",
+		'J': "Ĵ", 'j': "ĵ",
+		'K': "Ķ", 'k': "ķ",
+		'L': "ĹĻĽĿŁ", 'l': "ĺļľŀł",
+		'N': "ÑŃŅŇ", 'n': "ñńņň",
+		'O': "ÒÓÔÕÖØŌŎŐǑ", 'o': "òóôõöøōŏőǒ",
+		'R': "ŔŖŘ", 'r': "ŕŗř",
+		'S': "ŚŜŞŠȘ", 's': "śŝşšș",
+		'T': "ŢŤŦȚ", 't': "ţťŧț",
+		'U': "ÙÚÛÜŨŪŬŮŰŲǓ", 'u': "ùúûüũūŭůűųǔ",
+		'W': "Ŵ", 'w': "ŵ",
+		'Y': "ÝŸŶ", 'y': "ýÿŷ",
+		'Z': "ŹŻŽ", 'z': "źżž",
+	} {
+		for _, r := range accented {
+			diacritics[r] = base
+		}
+	}
+}
+
+// foldCase maps r to a canonical lowercase rune shared by every rune that
+// Unicode case folding considers equal, e.g. "K", "k" and the Kelvin sign
+func foldCase(r rune) rune {
+	if r < unicode.MaxASCII {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return unicode.ToLower(min)
+}
+
+// hasUpper reports whether runes contains an uppercase letter
+func hasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns a copy of runes prepared for comparison: case folded
+// unless caseSensitive, and with accents removed unless literal. Indices
+// stay aligned with runes.
+func normalize(runes []rune, caseSensitive, literal bool) []rune {
+	result := make([]rune, len(runes))
+	for i, r := range runes {
+		if !literal {
+			if base, ok := diacritics[r]; ok {
+				r = base
+			}
+		}
+		if !caseSensitive {
+			r = foldCase(r)
+		}
+		result[i] = r
+	}
+	return result
+}
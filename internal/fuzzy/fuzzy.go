@@ -2,84 +2,60 @@ package fuzzy
 
 import (
 	"sort"
-	"strings"
+	"unicode/utf8"
 )
 
 // Match represents a fuzzy match result
 type Match struct {
 	Text  string
 	Score int
+	// Positions holds the indices of the runes in Text matched by the
+	// pattern, in increasing order. It is empty for an empty pattern.
+	Positions []int
 }
 
 // FuzzyMatch performs fuzzy matching on a list of strings
-// Returns matches sorted by score (higher is better)
+// Returns matches sorted by score (higher is better), with shorter texts
+// first among equal scores
 func FuzzyMatch(pattern string, candidates []string) []Match {
-	pattern = strings.ToLower(pattern)
 	var matches []Match
 
 	for _, candidate := range candidates {
-		score := calculateScore(pattern, strings.ToLower(candidate))
-		if score > 0 {
-			matches = append(matches, Match{
-				Text:  candidate,
-				Score: score,
-			})
+		if m := MatchString(pattern, candidate); m.Score > 0 {
+			matches = append(matches, m)
 		}
 	}
 
-	// Sort by score descending
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].Score > matches[j].Score
+	// Sort by score descending, then by length, keeping candidate order
+	// for ties
+	sort.SliceStable(matches, func(i, j int) bool {
+		return Less(matches[i], matches[j])
 	})
 
 	return matches
 }
 
-// calculateScore computes a fuzzy match score
-// Returns 0 if pattern doesn't match
-func calculateScore(pattern, text string) int {
-	if pattern == "" {
-		return 1
-	}
-
-	// Exact match gets highest score
-	if text == pattern {
-		return 1000
-	}
-
-	// Contains exact pattern gets high score
-	if strings.Contains(text, pattern) {
-		return 500 + (100 - len(text)) // Prefer shorter matches
-	}
-
-	// Fuzzy match: all characters must appear in order
-	patternIdx := 0
-	score := 0
-	lastMatchIdx := -1
-	consecutiveBonus := 0
-
-	for i := 0; i < len(text) && patternIdx < len(pattern); i++ {
-		if text[i] == pattern[patternIdx] {
-			// Bonus for consecutive matches
-			if lastMatchIdx == i-1 {
-				consecutiveBonus += 10
-			}
-			// Bonus for matching at word boundaries
-			if i == 0 || text[i-1] == '-' || text[i-1] == '_' || text[i-1] == '/' {
-				score += 20
-			}
-			score += 10 + consecutiveBonus
-			lastMatchIdx = i
-			patternIdx++
-		}
+// Less reports whether match a ranks before match b: a higher score wins,
+// and a shorter text breaks ties so exact matches come first
+func Less(a, b Match) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
 	}
+	return utf8.RuneCountInString(a.Text) < utf8.RuneCountInString(b.Text)
+}
 
-	// Only return score if all pattern characters were matched
-	if patternIdx == len(pattern) {
-		return score
-	}
+// MatchString matches pattern against a single text. The returned Match
+// has a Score of 0 if the text doesn't match. Matching is smart-case and
+// ignores accents, see Query.
+func MatchString(pattern, text string) Match {
+	score, positions := match([]rune(pattern), []rune(text), false)
+	return Match{Text: text, Score: score, Positions: positions}
+}
 
-	return 0
+// Score returns the fuzzy match score of pattern against a single text,
+// or 0 if it doesn't match
+func Score(pattern, text string) int {
+	return MatchString(pattern, text).Score
 }
 
 // BestMatch returns the single best match, or empty string if none
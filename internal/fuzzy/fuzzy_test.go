@@ -1,6 +1,8 @@
 package fuzzy
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -130,22 +132,280 @@ func TestTopMatches_FewerThanN(t *testing.T) {
 	}
 }
 
-func TestCalculateScore_WordBoundaryBonus(t *testing.T) {
+func TestScore_WordBoundaryBonus(t *testing.T) {
 	// Test that word boundaries get bonus
-	score1 := calculateScore("fb", "foo-bar")   // f at start, b after hyphen
-	score2 := calculateScore("fb", "foobxxbar") // f and b not at boundaries
+	score1 := Score("fb", "foo-bar")   // f at start, b after hyphen
+	score2 := Score("fb", "foobxxbar") // f and b not at boundaries
 
 	if score1 <= score2 {
 		t.Errorf("expected word boundary match to score higher: boundary=%d, non-boundary=%d", score1, score2)
 	}
 }
 
-func TestCalculateScore_ConsecutiveBonus(t *testing.T) {
+func TestScore_ConsecutiveBonus(t *testing.T) {
 	// Consecutive matches should score higher
-	score1 := calculateScore("abc", "abcdef")  // consecutive
-	score2 := calculateScore("abc", "axbxcxx") // non-consecutive
+	score1 := Score("abc", "abcdef")  // consecutive
+	score2 := Score("abc", "axbxcxx") // non-consecutive
 
 	if score1 <= score2 {
 		t.Errorf("expected consecutive match to score higher: consecutive=%d, non-consecutive=%d", score1, score2)
 	}
 }
+
+func TestMatchString_Positions(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		want          []int
+	}{
+		{"foo", "foo", []int{0, 1, 2}},
+		{"bar", "foo-Bar", []int{4, 5, 6}},
+		{"fb", "foo-bar", []int{0, 4}},
+		{"éb", "café-bar", []int{3, 5}},
+		{"", "foo", nil},
+		{"xyz", "foo", nil},
+	}
+
+	for _, tt := range tests {
+		got := MatchString(tt.pattern, tt.text).Positions
+		if len(got) != len(tt.want) {
+			t.Errorf("MatchString(%q, %q).Positions = %v, want %v", tt.pattern, tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("MatchString(%q, %q).Positions = %v, want %v", tt.pattern, tt.text, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFuzzyMatch_Positions(t *testing.T) {
+	matches := FuzzyMatch("ab", []string{"xaxb"})
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 match, got %d", len(matches))
+	}
+	if got := matches[0].Positions; len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("expected positions [1 3], got %v", got)
+	}
+}
+
+// rankingCorpus pins down how the scorer ranks typical worktree and branch
+// names. If a change to the scorer reorders any of these, the change
+// should be deliberate and the expectations updated with it.
+var rankingCorpus = []struct {
+	name       string
+	pattern    string
+	candidates []string
+	want       []string
+}{
+	{"exact before prefix", "foo", []string{"foo-bar", "foo"}, []string{"foo", "foo-bar"}},
+	{"word boundary", "fb", []string{"fxxxb", "foo-bar"}, []string{"foo-bar", "fxxxb"}},
+	{"camel case", "mf", []string{"amfx", "myFeature"}, []string{"myFeature", "amfx"}},
+	{"path separator", "fl", []string{"fooled", "feature/login"}, []string{"feature/login", "fooled"}},
+	{"shorter gap", "ab", []string{"axxxxb", "a-b"}, []string{"a-b", "axxxxb"}},
+	{"prefix over word over infix", "api", []string{"rapid", "api-server", "my-api"}, []string{"api-server", "my-api", "rapid"}},
+	{"digits", "wt12", []string{"wt-jira-123", "wt-1-2", "wt12"}, []string{"wt12", "wt-1-2", "wt-jira-123"}},
+	{"word start over infix", "feat", []string{"defeat", "feature", "repo-feature-x"}, []string{"feature", "repo-feature-x", "defeat"}},
+	{"initials", "rf", []string{"xrfx", "refs", "repo-feature"}, []string{"repo-feature", "refs", "xrfx"}},
+}
+
+func TestFuzzyMatch_RankingCorpus(t *testing.T) {
+	for _, tt := range rankingCorpus {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := FuzzyMatch(tt.pattern, tt.candidates)
+			got := make([]string, len(matches))
+			for i, m := range matches {
+				got[i] = m.Text
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("FuzzyMatch(%q) ranked %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchString_OptimalAlignment(t *testing.T) {
+	// A greedy scan would take the first "a", "b" and "c"; the best
+	// alignment is the contiguous word at the end
+	m := MatchString("abc", "a-xbc-abc")
+	if len(m.Positions) != 3 || m.Positions[0] != 6 || m.Positions[1] != 7 || m.Positions[2] != 8 {
+		t.Errorf("expected positions [6 7 8], got %v", m.Positions)
+	}
+}
+
+func TestMatchString_LongText(t *testing.T) {
+	// Long gaps lower the score but never below a match
+	if score := Score("ab", "a"+strings.Repeat("x", 500)+"b"); score < 1 {
+		t.Errorf("expected a positive score, got %d", score)
+	}
+	if Score("long", strings.Repeat("x", 200)+"-long") != Score("long", "x-long") {
+		t.Error("expected leading characters not to affect the score")
+	}
+}
+
+func BenchmarkFuzzyMatch(b *testing.B) {
+	var candidates []string
+	for _, repo := range []string{"api", "web", "infra", "mobile-app", "docs"} {
+		for _, branch := range []string{"main", "feature/login", "fix/JIRA-1234-timeout", "release-2024.10", "chore/update-deps", "feature/search-ranking"} {
+			candidates = append(candidates, repo+"-"+branch)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FuzzyMatch("frank", candidates)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []term
+	}{
+		{"api", []term{{kind: termFuzzy, text: []rune("api")}}},
+		{"  api  web ", []term{{kind: termFuzzy, text: []rune("api")}, {kind: termFuzzy, text: []rune("web")}}},
+		{"'api", []term{{kind: termExact, text: []rune("api")}}},
+		{"^api", []term{{kind: termPrefix, text: []rune("api")}}},
+		{"api$", []term{{kind: termSuffix, text: []rune("api")}}},
+		{"^api$", []term{{kind: termEqual, text: []rune("api")}}},
+		{"!old", []term{{kind: termExact, text: []rune("old"), negate: true}}},
+		{"!^old", []term{{kind: termPrefix, text: []rune("old"), negate: true}}},
+		{"my\\ api", []term{{kind: termFuzzy, text: []rune("my api")}}},
+		{"! $", []term{{kind: termFuzzy, text: []rune("!")}, {kind: termFuzzy, text: []rune("$")}}},
+	}
+
+	for _, tt := range tests {
+		got := ParseQuery(tt.query).terms
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].kind != tt.want[i].kind || got[i].negate != tt.want[i].negate || string(got[i].text) != string(tt.want[i].text) {
+				t.Errorf("ParseQuery(%q) term %d = %+v, want %+v", tt.query, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestQuery_Filter(t *testing.T) {
+	candidates := []string{"api-server", "api-old", "web-api", "old-web", "apiary"}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"api !old", []string{"apiary", "api-server", "web-api"}},
+		{"^api", []string{"apiary", "api-old", "api-server"}},
+		{"api$", []string{"web-api"}},
+		{"'pi-", []string{"api-old", "api-server"}},
+		{"^apiary$", []string{"apiary"}},
+		{"web old", []string{"old-web"}},
+		{"!api", []string{"old-web"}},
+		{"", candidates},
+	}
+
+	for _, tt := range tests {
+		matches := ParseQuery(tt.query).Filter(candidates)
+		got := make([]string, len(matches))
+		for i, m := range matches {
+			got[i] = m.Text
+		}
+		if tt.query == "" {
+			// An empty query matches everything, shortest first
+			if len(got) != len(candidates) {
+				t.Errorf("expected all candidates for an empty query, got %v", got)
+			}
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Filter(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_MatchFields(t *testing.T) {
+	q := ParseQuery("repo login")
+	score, matches := q.MatchFields("repo-auth", "feature/login")
+	if score == 0 {
+		t.Fatal("expected terms to match across fields")
+	}
+	if got := matches[0].Positions; len(got) != 4 || got[0] != 0 {
+		t.Errorf("expected repo positions in the name, got %v", got)
+	}
+	if got := matches[1].Positions; len(got) != 5 || got[0] != 8 {
+		t.Errorf("expected login positions in the branch, got %v", got)
+	}
+
+	if score, _ := ParseQuery("repo !login").MatchFields("repo-auth", "feature/login"); score != 0 {
+		t.Error("expected a negated term matching any field to reject the record")
+	}
+}
+
+func TestMatchString_SmartCase(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		want          bool
+	}{
+		{"feature", "Feature-X", true},
+		{"Feature", "Feature-X", true},
+		{"Feature", "feature-x", false},
+		{"fX", "feature-X", true},
+		{"fX", "feature-x", false},
+		// Unicode case folding
+		{"straße", "STRASSE-straße", true},
+		{"σ", "ΛΟΓΟΣ", true},
+		{"ς", "λογοσ", true},
+		{"k", "K", true},
+		{"ÉTÉ", "été", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchString(tt.pattern, tt.text).Score > 0; got != tt.want {
+			t.Errorf("MatchString(%q, %q) matched = %v, want %v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMatchString_Unicode(t *testing.T) {
+	tests := []struct {
+		pattern, text string
+		want          []int
+	}{
+		// Multi-byte runes are matched whole, never mid-rune
+		{"機能", "feature/機能-login", []int{8, 9}},
+		{"ü", "über", []int{0}},
+		// Accents are ignored by default
+		{"cafe", "Café-menu", []int{0, 1, 2, 3}},
+		{"naive", "naïve", []int{0, 1, 2, 3, 4}},
+		{"é", "resume", []int{1}},
+	}
+
+	for _, tt := range tests {
+		got := MatchString(tt.pattern, tt.text).Positions
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("MatchString(%q, %q).Positions = %v, want %v", tt.pattern, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQuery_Literal(t *testing.T) {
+	q := ParseQuery("cafe")
+	if q.Match("café").Score == 0 {
+		t.Error("expected accents to be ignored by default")
+	}
+	q.Literal = true
+	if q.Match("café").Score != 0 {
+		t.Error("expected a literal query not to match accented letters")
+	}
+	if q.Match("cafe").Score == 0 {
+		t.Error("expected a literal query to match exactly")
+	}
+
+	// Exact terms are smart-case too
+	if ParseQuery("'Api").Match("rapid").Score != 0 {
+		t.Error("expected an uppercase exact term to be case-sensitive")
+	}
+	if ParseQuery("^api").Match("API-server").Score == 0 {
+		t.Error("expected a lowercase prefix term to be case-insensitive")
+	}
+}
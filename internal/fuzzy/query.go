@@ -0,0 +1,293 @@
+package fuzzy
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// termKind is how a query term is compared against text
+type termKind int
+
+const (
+	// termFuzzy matches the term's characters in order, with gaps
+	termFuzzy termKind = iota
+	// termExact matches the term as a substring: 'term
+	termExact
+	// termPrefix matches the start of the text: ^term
+	termPrefix
+	// termSuffix matches the end of the text: term$
+	termSuffix
+	// termEqual matches the whole text: ^term$
+	termEqual
+)
+
+// term is a single space-separated part of a query
+type term struct {
+	kind   termKind
+	text   []rune
+	negate bool
+}
+
+// Query is a parsed search query in fzf's syntax. It holds space-separated
+// terms that must all match:
+//
+//	abc     fuzzy match
+//	'abc    contains "abc"
+//	^abc    starts with "abc"
+//	abc$    ends with "abc"
+//	^abc$   is exactly "abc"
+//	!abc    doesn't contain "abc" (also !^abc, !abc$ and !^abc$)
+//
+// A backslash escapes a space that is part of a term.
+//
+// Terms are smart-case: a term is case-insensitive, using Unicode case
+// folding, unless it contains an uppercase letter. Accented letters match
+// their base letter, so "cafe" matches "café", unless Literal is set.
+type Query struct {
+	// Literal disables accent folding
+	Literal bool
+	terms   []term
+}
+
+// ParseQuery parses a query string
+func ParseQuery(s string) Query {
+	var q Query
+	for _, token := range splitTokens(s) {
+		q.terms = append(q.terms, parseTerm(token))
+	}
+	return q
+}
+
+// splitTokens splits s on unescaped whitespace
+func splitTokens(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != ' ' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case unicode.IsSpace(r):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// parseTerm parses one token. Operators that would leave the term empty,
+// such as a lone "!" or "$", are taken literally.
+func parseTerm(token string) term {
+	t := term{kind: termFuzzy}
+	text := token
+
+	if strings.HasPrefix(text, "!") && len(text) > 1 {
+		t.negate = true
+		// Negated terms are never fuzzy
+		t.kind = termExact
+		text = text[1:]
+	}
+
+	switch {
+	case strings.HasPrefix(text, "'") && len(text) > 1:
+		t.kind = termExact
+		text = text[1:]
+	case strings.HasPrefix(text, "^") && len(text) > 1:
+		t.kind = termPrefix
+		text = text[1:]
+		if strings.HasSuffix(text, "$") && len(text) > 1 {
+			t.kind = termEqual
+			text = text[:len(text)-1]
+		}
+	case strings.HasSuffix(text, "$") && len(text) > 1:
+		t.kind = termSuffix
+		text = text[:len(text)-1]
+	}
+
+	t.text = []rune(text)
+	return t
+}
+
+// Empty reports whether the query has no terms, in which case it matches
+// everything
+func (q Query) Empty() bool {
+	return len(q.terms) == 0
+}
+
+// Negated reports whether the query has terms and all of them are
+// negated, so that it matches whatever doesn't contain them
+func (q Query) Negated() bool {
+	for _, t := range q.terms {
+		if !t.negate {
+			return false
+		}
+	}
+	return len(q.terms) > 0
+}
+
+// Match matches the query against a single text. The returned Match has
+// a Score of 0 if the text doesn't match.
+func (q Query) Match(text string) Match {
+	score, matches := q.MatchFields(text)
+	m := matches[0]
+	m.Score = score
+	return m
+}
+
+// MatchFields matches the query against a record made of several fields,
+// such as a worktree's name and branch. Each term may match any field, and
+// a negated term rejects the record if it matches any field. It returns
+// the total score, 0 if the record doesn't match, and a Match per field
+// holding the positions matched in it.
+func (q Query) MatchFields(fields ...string) (int, []Match) {
+	matches := make([]Match, len(fields))
+	runes := make([][]rune, len(fields))
+	for i, field := range fields {
+		matches[i].Text = field
+		runes[i] = []rune(field)
+	}
+	if q.Empty() {
+		return 1, matches
+	}
+
+	total := 0
+	for _, t := range q.terms {
+		best, bestField := 0, -1
+		var bestPositions []int
+		for i, field := range runes {
+			score, positions := t.match(field, q.Literal)
+			if score > best {
+				best, bestField, bestPositions = score, i, positions
+			}
+		}
+
+		if t.negate {
+			if bestField >= 0 {
+				return 0, clearMatches(matches)
+			}
+			continue
+		}
+		if bestField < 0 {
+			return 0, clearMatches(matches)
+		}
+		total += best
+		matches[bestField].Positions = mergePositions(matches[bestField].Positions, bestPositions)
+	}
+
+	// A query of only negated terms matches whatever it doesn't exclude
+	if total == 0 {
+		total = 1
+	}
+	for i := range matches {
+		matches[i].Score = total
+	}
+	return total, matches
+}
+
+// match scores the term against text, ignoring negation
+func (t term) match(text []rune, literal bool) (int, []int) {
+	if t.kind == termFuzzy {
+		return match(t.text, text, literal)
+	}
+
+	caseSensitive := hasUpper(t.text)
+	pattern := normalize(t.text, caseSensitive, literal)
+	foldedText := normalize(text, caseSensitive, literal)
+	m, n := len(pattern), len(foldedText)
+	if m > n {
+		return 0, nil
+	}
+
+	var starts []int
+	switch t.kind {
+	case termExact:
+		for i := 0; i+m <= n; i++ {
+			starts = append(starts, i)
+		}
+	case termPrefix:
+		starts = []int{0}
+	case termSuffix:
+		starts = []int{n - m}
+	case termEqual:
+		if m == n {
+			starts = []int{0}
+		}
+	}
+
+	bonus := bonuses(text)
+	best, bestStart := 0, -1
+	for _, start := range starts {
+		if string(foldedText[start:start+m]) != string(pattern) {
+			continue
+		}
+		if score := runScore(bonus, start, m); score > best {
+			best, bestStart = score, start
+		}
+	}
+	if bestStart < 0 {
+		return 0, nil
+	}
+
+	positions := make([]int, m)
+	for i := range positions {
+		positions[i] = bestStart + i
+	}
+	return best, positions
+}
+
+// Filter matches the query against each candidate and returns the matches
+// sorted like FuzzyMatch's
+func (q Query) Filter(candidates []string) []Match {
+	var matches []Match
+	for _, candidate := range candidates {
+		if m := q.Match(candidate); m.Score > 0 {
+			matches = append(matches, m)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return Less(matches[i], matches[j])
+	})
+
+	return matches
+}
+
+// mergePositions returns the sorted union of two sorted position lists
+func mergePositions(a, b []int) []int {
+	if len(a) == 0 {
+		return b
+	}
+	merged := append(append([]int(nil), a...), b...)
+	sort.Ints(merged)
+	result := merged[:1]
+	for _, p := range merged[1:] {
+		if p != result[len(result)-1] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func clearMatches(matches []Match) []Match {
+	for i := range matches {
+		matches[i].Positions = nil
+	}
+	return matches
+}
@@ -0,0 +1,276 @@
+package fuzzy
+
+import "unicode"
+
+// The scorer finds the best alignment of the pattern in the text, in the
+// spirit of fzf's algorithm: every matched character earns scoreMatch plus
+// a bonus for where it sits, and every skipped character between matches
+// costs a gap penalty. Characters before the first match and after the
+// last one are free, so a match anywhere in the text is as good as a match
+// at the start, apart from the start's boundary bonus.
+const (
+	scoreMatch        = 16
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+
+	// bonusBoundary rewards a match at the start of a word, e.g. the "b"
+	// in "foo-bar"
+	bonusBoundary = scoreMatch / 2
+	// bonusBoundaryWhite is for the start of the text or after whitespace
+	bonusBoundaryWhite = bonusBoundary + 2
+	// bonusBoundaryDelimiter is for matches after a path separator or
+	// similar delimiter, e.g. the "l" in "feature/login"
+	bonusBoundaryDelimiter = bonusBoundary + 1
+	// bonusNonWord rewards matching punctuation, which is rarely typed by
+	// accident
+	bonusNonWord = scoreMatch / 2
+	// bonusCamel123 is for a lower-to-upper or letter-to-digit transition,
+	// e.g. the "F" in "myFeature" or the "1" in "jira123"
+	bonusCamel123 = bonusBoundary + scoreGapExtension
+	// bonusConsecutive is the minimum bonus for each character of a run
+	// of adjacent matches, enough to outweigh starting a gap
+	bonusConsecutive = -(scoreGapStart + scoreGapExtension)
+	// bonusFirstCharMultiplier weighs the bonus of the first matched
+	// character, since users tend to type the start of a word first
+	bonusFirstCharMultiplier = 2
+)
+
+type charClass int
+
+const (
+	charWhite charClass = iota
+	charNonWord
+	charDelimiter
+	charLower
+	charUpper
+	charLetter
+	charNumber
+)
+
+func classOf(r rune) charClass {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return charLower
+	case r >= 'A' && r <= 'Z':
+		return charUpper
+	case r >= '0' && r <= '9':
+		return charNumber
+	case r == '/' || r == '\\' || r == ',' || r == ':' || r == ';' || r == '|':
+		return charDelimiter
+	case unicode.IsSpace(r):
+		return charWhite
+	case unicode.IsLower(r):
+		return charLower
+	case unicode.IsUpper(r):
+		return charUpper
+	case unicode.IsLetter(r):
+		return charLetter
+	case unicode.IsNumber(r):
+		return charNumber
+	}
+	return charNonWord
+}
+
+// bonusFor returns the bonus for matching a character of class class
+// that follows one of class prev
+func bonusFor(prev, class charClass) int {
+	if class > charDelimiter {
+		switch prev {
+		case charWhite:
+			return bonusBoundaryWhite
+		case charDelimiter:
+			return bonusBoundaryDelimiter
+		case charNonWord:
+			return bonusBoundary
+		}
+	}
+	switch {
+	case prev == charLower && class == charUpper,
+		prev != charNumber && class == charNumber:
+		return bonusCamel123
+	case class == charNonWord || class == charDelimiter:
+		return bonusNonWord
+	case class == charWhite:
+		return bonusBoundaryWhite
+	}
+	return 0
+}
+
+// bonuses returns the bonus for matching each rune of text
+func bonuses(text []rune) []int {
+	bonus := make([]int, len(text))
+	prev := charWhite
+	for j, r := range text {
+		class := classOf(r)
+		bonus[j] = bonusFor(prev, class)
+		prev = class
+	}
+	return bonus
+}
+
+// runScore scores length runes matched as one run of adjacent characters
+// starting at text[start], the way match scores such a run
+func runScore(bonus []int, start, length int) int {
+	first := bonus[start]
+	score := scoreMatch + first*bonusFirstCharMultiplier
+	for j := start + 1; j < start+length; j++ {
+		b := bonus[j]
+		if b >= bonusBoundary && b > first {
+			// A stronger boundary starts a new run
+			first = b
+		} else {
+			b = max(b, bonusConsecutive, first)
+		}
+		score += scoreMatch + b
+	}
+	return score
+}
+
+// minScore marks unreachable cells of the scoring matrices
+const minScore = -1 << 30
+
+// match finds the best-scoring alignment of pattern in text and returns
+// its score and the matched rune positions. Returns a score of 0 if
+// pattern doesn't match. A match always scores at least 1, however long
+// its gaps. Comparison is smart-case: case-insensitive unless pattern
+// has an uppercase letter. Accents are ignored unless literal is set.
+func match(pattern, text []rune, literal bool) (int, []int) {
+	if len(pattern) == 0 {
+		return 1, nil
+	}
+	m, n := len(pattern), len(text)
+	if m > n {
+		return 0, nil
+	}
+
+	caseSensitive := hasUpper(pattern)
+	foldedPattern := normalize(pattern, caseSensitive, literal)
+	foldedText := normalize(text, caseSensitive, literal)
+	bonus := bonuses(text)
+
+	// Every pattern character must appear in order; this also bounds the
+	// columns each row can match in
+	first := make([]int, m)
+	j := 0
+	for i, r := range foldedPattern {
+		for j < n && foldedText[j] != r {
+			j++
+		}
+		if j == n {
+			return 0, nil
+		}
+		first[i] = j
+		j++
+	}
+
+	// score[i][j] is the best score of pattern[:i+1] with pattern[i]
+	// matched at text[j]. run[i][j] is the length of the run of adjacent
+	// matches ending there, and diag[i][j] records whether the best path
+	// continued a run from (i-1, j-1). gap[i][j] is the best score of
+	// pattern[:i+1] ending before text[j] with text[j] skipped, and
+	// gapFrom[i][j] is where that path's last match was.
+	score := newMatrix(m, n, minScore)
+	run := newMatrix(m, n, 0)
+	gap := newMatrix(m, n, minScore)
+	gapFrom := newMatrix(m, n, -1)
+	diag := make([][]bool, m)
+	for i := range diag {
+		diag[i] = make([]bool, n)
+	}
+
+	for i := 0; i < m; i++ {
+		for j := first[i]; j < n; j++ {
+			if j > 0 {
+				// Extend the gap after the best earlier match of pattern[i]
+				if s := score[i][j-1]; s > minScore && s+scoreGapStart >= gap[i][j-1]+scoreGapExtension {
+					gap[i][j] = s + scoreGapStart
+					gapFrom[i][j] = j - 1
+				} else if gap[i][j-1] > minScore {
+					gap[i][j] = gap[i][j-1] + scoreGapExtension
+					gapFrom[i][j] = gapFrom[i][j-1]
+				}
+			}
+
+			if foldedText[j] != foldedPattern[i] {
+				continue
+			}
+			b := bonus[j]
+			if i == 0 {
+				score[i][j] = scoreMatch + b*bonusFirstCharMultiplier
+				run[i][j] = 1
+				continue
+			}
+			if j == 0 {
+				continue
+			}
+
+			// Continue a run of adjacent matches. A run keeps the bonus
+			// of its first character unless a stronger boundary starts
+			// a new one.
+			consecutive, length := minScore, 1
+			if s := score[i-1][j-1]; s > minScore {
+				runBonus := b
+				if fb := bonus[j-run[i-1][j-1]]; b < bonusBoundary || b <= fb {
+					runBonus = max(b, bonusConsecutive, fb)
+					length = run[i-1][j-1] + 1
+				}
+				consecutive = s + scoreMatch + runBonus
+			}
+			// Or start a new run after skipping some characters
+			afterGap := minScore
+			if g := gap[i-1][j-1]; g > minScore {
+				afterGap = g + scoreMatch + b
+			}
+
+			switch {
+			case consecutive > minScore && consecutive >= afterGap:
+				score[i][j] = consecutive
+				run[i][j] = length
+				diag[i][j] = true
+			case afterGap > minScore:
+				score[i][j] = afterGap
+				run[i][j] = 1
+			}
+		}
+	}
+
+	// The best alignment ends wherever the last character scores highest
+	best, end := minScore, -1
+	for j := first[m-1]; j < n; j++ {
+		if score[m-1][j] > best {
+			best, end = score[m-1][j], j
+		}
+	}
+	if end < 0 {
+		return 0, nil
+	}
+
+	positions := make([]int, m)
+	for i := m - 1; i >= 0; i-- {
+		positions[i] = end
+		if i > 0 {
+			if diag[i][end] {
+				end--
+			} else {
+				end = gapFrom[i-1][end-1]
+			}
+		}
+	}
+
+	if best < 1 {
+		best = 1
+	}
+	return best, positions
+}
+
+func newMatrix(rows, cols, value int) [][]int {
+	matrix := make([][]int, rows)
+	cells := make([]int, rows*cols)
+	for i := range cells {
+		cells[i] = value
+	}
+	for i := range matrix {
+		matrix[i] = cells[i*cols : (i+1)*cols]
+	}
+	return matrix
+}
@@ -1,24 +1,48 @@
 package git
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
-// GetWTHome returns the WT_HOME directory, defaulting to ~/worktrees
-func GetWTHome() (string, error) {
-	wtHome := os.Getenv("WT_HOME")
-	if wtHome == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return "", fmt.Errorf("failed to get home directory: %w", err)
-		}
-		wtHome = filepath.Join(home, "worktrees")
-	}
-	return wtHome, nil
+// Sentinel errors for the git failures callers tell apart, for use with
+// errors.Is
+var (
+	// ErrNotARepo is returned when a command needs to run inside a git
+	// repository but doesn't
+	ErrNotARepo = errors.New("not in a git repository")
+	// ErrRefNotFound is returned when a ref doesn't resolve to a commit
+	ErrRefNotFound = errors.New("ref not found")
+	// ErrBranchExists is returned when a new branch would replace one
+	ErrBranchExists = errors.New("branch already exists")
+	// ErrDirty is returned when git refuses to remove a worktree with
+	// modified or untracked files
+	ErrDirty = errors.New("worktree has modified or untracked files")
+	// ErrLocked is returned when git refuses to remove a locked worktree
+	ErrLocked = errors.New("worktree is locked")
+)
+
+// kindError is an error that also matches the sentinel error of its kind,
+// keeping its own message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// errorf returns an error with the formatted message that matches kind
+func errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
 }
 
 // GetRepoName returns the name of the current git repository
@@ -26,7 +50,7 @@ func GetRepoName() (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
 	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("not in a git repository: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrNotARepo, err)
 	}
 	repoPath := strings.TrimSpace(string(output))
 	return filepath.Base(repoPath), nil
@@ -37,7 +61,7 @@ func GetRepoRoot() (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
 	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("not in a git repository: %w", err)
+		return "", fmt.Errorf("%w: %w", ErrNotARepo, err)
 	}
 	return strings.TrimSpace(string(output)), nil
 }
@@ -66,41 +90,234 @@ func CreateWorktree(targetPath, branchName string) error {
 	return nil
 }
 
-// RemoveWorktree removes a git worktree
-func RemoveWorktree(worktreePath string) error {
-	cmd := exec.Command("git", "worktree", "remove", worktreePath, "--force")
+// CreateWorktreeFrom creates a new git worktree at the specified path on a new
+// branch that starts at baseRef. baseRef may be a branch, tag, commit or
+// remote-tracking ref.
+func CreateWorktreeFrom(targetPath, branchName, baseRef string) error {
+	if err := VerifyRef(baseRef); err != nil {
+		return err
+	}
+	if BranchExists(branchName) {
+		return errorf(ErrBranchExists, "branch '%s' already exists; omit --from to check it out", branchName)
+	}
+
+	// --no-track keeps the new branch from tracking the base when it is a
+	// remote-tracking ref such as origin/main
+	cmd := exec.Command("git", "worktree", "add", "--no-track", "-b", branchName, targetPath, baseRef)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to remove worktree: %s", string(output))
+		return fmt.Errorf("failed to create worktree: %s", strings.TrimSpace(string(output)))
 	}
 	return nil
 }
 
-// ListWorktrees returns a list of all worktree paths under WT_HOME
-func ListWorktrees() ([]string, error) {
-	wtHome, err := GetWTHome()
-	if err != nil {
-		return nil, err
+// VerifyRef returns an error if ref does not resolve to a commit
+func VerifyRef(ref string) error {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err := cmd.Run(); err != nil {
+		return errorf(ErrRefNotFound, "base ref '%s' does not exist", ref)
 	}
+	return nil
+}
 
-	var worktrees []string
-	entries, err := os.ReadDir(wtHome)
+// BranchExists reports whether a local branch with the given name exists
+func BranchExists(branchName string) bool {
+	cmd := exec.Command("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branchName)
+	return cmd.Run() == nil
+}
+
+// RemoveWorktree removes a worktree of the repository at repoPath. Unless
+// force is set, git refuses to remove a worktree with modified or
+// untracked files, and the error matches ErrDirty; for a locked worktree
+// it matches ErrLocked.
+func RemoveWorktree(repoPath, worktreePath string, force bool) error {
+	args := []string{"-C", repoPath, "worktree", "remove", worktreePath}
+	if force {
+		args = append(args, "--force")
+	}
+	cmd := exec.Command("git", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(output))
+		switch {
+		case strings.Contains(msg, "contains modified or untracked files"):
+			return errorf(ErrDirty, "failed to remove worktree: %s", msg)
+		case strings.Contains(msg, "locked working tree"):
+			return errorf(ErrLocked, "failed to remove worktree: %s", msg)
+		}
+		return fmt.Errorf("failed to remove worktree: %s", msg)
+	}
+	return nil
+}
+
+// ListRefs returns the names of the refs under prefix, such as
+// "refs/heads/", with the prefix removed. Symbolic refs like
+// refs/remotes/origin/HEAD are left out.
+func ListRefs(prefix string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)\t%(symref)", prefix)
+	output, err := cmd.Output()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return worktrees, nil
+		return nil, fmt.Errorf("failed to list refs: %w", err)
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		ref, symref, _ := strings.Cut(line, "\t")
+		if ref == "" || symref != "" {
+			continue
 		}
-		return nil, fmt.Errorf("failed to read WT_HOME directory: %w", err)
+		names = append(names, strings.TrimPrefix(ref, prefix))
+	}
+	return names, nil
+}
+
+// HeadBranch returns the branch checked out in the worktree at dir, or an
+// error if its HEAD is detached
+func HeadBranch(dir string) (string, error) {
+	cmd := exec.Command("git", "-C", dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no branch checked out in %s", dir)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsMerged reports whether every commit of the local branch is reachable
+// from base in the repository at repoPath
+func IsMerged(repoPath, branch, base string) (bool, error) {
+	cmd := exec.Command("git", "-C", repoPath, "merge-base", "--is-ancestor", "refs/heads/"+branch, base)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check whether '%s' is merged into '%s': %w", branch, base, err)
+}
+
+// DeleteBranch deletes a local branch of the repository at repoPath,
+// merged or not; callers decide whether it is safe to
+func DeleteBranch(repoPath, branch string) error {
+	cmd := exec.Command("git", "-C", repoPath, "branch", "-D", branch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete branch '%s': %s", branch, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// BranchMoved reports whether the local branch has been updated since it
+// was created, going by its reflog. A branch without a reflog counts as
+// moved.
+func BranchMoved(repoPath, branch string) (bool, error) {
+	cmd := exec.Command("git", "-C", repoPath, "reflog", "show", "--format=%H", "refs/heads/"+branch, "--")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to read the reflog of '%s': %w", branch, err)
+	}
+	return strings.Count(string(output), "\n") != 1, nil
+}
+
+// ResolveCommit returns the hash of the commit ref points to in the
+// repository at repoPath
+func ResolveCommit(repoPath, ref string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errorf(ErrRefNotFound, "'%s' is not a valid commit", ref)
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			worktreePath := filepath.Join(wtHome, entry.Name())
-			// Check if it's a valid git worktree
-			gitDir := filepath.Join(worktreePath, ".git")
-			if _, err := os.Stat(gitDir); err == nil {
-				worktrees = append(worktrees, entry.Name())
-			}
+// GoneBranches returns the local branches of the repository at repoPath
+// whose upstream no longer exists, as git branch -vv marks [gone]
+func GoneBranches(repoPath string) (map[string]bool, error) {
+	cmd := exec.Command("git", "-C", repoPath, "for-each-ref", "--format=%(refname:short)%00%(upstream:track)", "refs/heads/")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+	gone := map[string]bool{}
+	for _, line := range strings.Split(string(output), "\n") {
+		if name, track, ok := strings.Cut(line, "\x00"); ok && track == "[gone]" {
+			gone[name] = true
 		}
 	}
+	return gone, nil
+}
 
-	return worktrees, nil
+// AddWorktree adds a worktree of the repository at repoPath at targetPath.
+// It checks out branch if it exists, and otherwise creates it at commit;
+// without a branch, it detaches HEAD at commit.
+func AddWorktree(repoPath, targetPath, branch, commit string) error {
+	args := []string{"-C", repoPath, "worktree", "add"}
+	switch {
+	case branch == "":
+		args = append(args, "--detach", targetPath, commit)
+	case exec.Command("git", "-C", repoPath, "show-ref", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil:
+		args = append(args, targetPath, branch)
+	default:
+		args = append(args, "-b", branch, targetPath, commit)
+	}
+	cmd := exec.Command("git", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create worktree: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// UpdateRef points ref at commit in the repository at repoPath
+func UpdateRef(repoPath, ref, commit string) error {
+	cmd := exec.Command("git", "-C", repoPath, "update-ref", ref, commit)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update %s: %s", ref, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// DeleteRef deletes ref in the repository at repoPath
+func DeleteRef(repoPath, ref string) error {
+	cmd := exec.Command("git", "-C", repoPath, "update-ref", "-d", ref)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete %s: %s", ref, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// DiffHead returns the staged and unstaged changes to tracked files in the
+// worktree at path as a binary patch, empty if there are none
+func DiffHead(path string) ([]byte, error) {
+	cmd := exec.Command("git", "-C", path, "diff", "--binary", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", path, err)
+	}
+	return output, nil
+}
+
+// ApplyPatch applies the patch in patchFile to the files of the worktree
+// at path
+func ApplyPatch(path, patchFile string) error {
+	cmd := exec.Command("git", "-C", path, "apply", "--binary", "--whitespace=nowarn", patchFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply changes: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// UntrackedFiles returns the paths of the untracked files in the worktree
+// at path, relative to it, leaving out ignored ones. Nested repositories
+// are listed as directories with a trailing slash.
+func UntrackedFiles(path string) ([]string, error) {
+	cmd := exec.Command("git", "-C", path, "ls-files", "--others", "--exclude-standard", "-z")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files in %s: %w", path, err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
 }
@@ -1,53 +1,23 @@
 package git
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
-func TestGetWTHome_Default(t *testing.T) {
-	// Unset WT_HOME to test default
-	original := os.Getenv("WT_HOME")
-	os.Unsetenv("WT_HOME")
-	defer func() {
-		if original != "" {
-			os.Setenv("WT_HOME", original)
-		}
-	}()
-
-	wtHome, err := GetWTHome()
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	home, _ := os.UserHomeDir()
-	expected := filepath.Join(home, "worktrees")
-	if wtHome != expected {
-		t.Errorf("expected %s, got %s", expected, wtHome)
-	}
-}
-
-func TestGetWTHome_EnvVar(t *testing.T) {
-	original := os.Getenv("WT_HOME")
-	defer func() {
-		if original != "" {
-			os.Setenv("WT_HOME", original)
-		} else {
-			os.Unsetenv("WT_HOME")
-		}
-	}()
-
-	expected := "/custom/worktrees/path"
-	os.Setenv("WT_HOME", expected)
-
-	wtHome, err := GetWTHome()
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+// runGit runs git in dir, failing the test on error
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
 	}
-
-	if wtHome != expected {
-		t.Errorf("expected %s, got %s", expected, wtHome)
+	cmd := exec.Command("git", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
 	}
 }
 
@@ -96,26 +66,15 @@ func TestGetCurrentBranch_InGitRepo(t *testing.T) {
 	}
 }
 
-func TestListWorktrees_EmptyDir(t *testing.T) {
-	// Create a temporary directory for WT_HOME
+func TestListWorktreesIn_EmptyDir(t *testing.T) {
+	// Create a temporary directory for the worktrees
 	tmpDir, err := os.MkdirTemp("", "wt-test-*")
 	if err != nil {
 		t.Fatalf("failed to create temp dir: %v", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Set WT_HOME to temp directory
-	original := os.Getenv("WT_HOME")
-	os.Setenv("WT_HOME", tmpDir)
-	defer func() {
-		if original != "" {
-			os.Setenv("WT_HOME", original)
-		} else {
-			os.Unsetenv("WT_HOME")
-		}
-	}()
-
-	worktrees, err := ListWorktrees()
+	worktrees, err := ListWorktreesIn(tmpDir)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -125,19 +84,8 @@ func TestListWorktrees_EmptyDir(t *testing.T) {
 	}
 }
 
-func TestListWorktrees_NonExistentDir(t *testing.T) {
-	// Set WT_HOME to non-existent directory
-	original := os.Getenv("WT_HOME")
-	os.Setenv("WT_HOME", "/nonexistent/path/that/does/not/exist")
-	defer func() {
-		if original != "" {
-			os.Setenv("WT_HOME", original)
-		} else {
-			os.Unsetenv("WT_HOME")
-		}
-	}()
-
-	worktrees, err := ListWorktrees()
+func TestListWorktreesIn_NonExistentDir(t *testing.T) {
+	worktrees, err := ListWorktreesIn("/nonexistent/path/that/does/not/exist")
 	if err != nil {
 		t.Fatalf("unexpected error for non-existent dir: %v", err)
 	}
@@ -147,22 +95,26 @@ func TestListWorktrees_NonExistentDir(t *testing.T) {
 	}
 }
 
-func TestListWorktrees_WithMockWorktrees(t *testing.T) {
-	// Create a temporary directory for WT_HOME
+func TestListWorktreesIn_WithWorktrees(t *testing.T) {
+	// Create a temporary directory for the worktrees
 	tmpDir, err := os.MkdirTemp("", "wt-test-*")
 	if err != nil {
 		t.Fatalf("failed to create temp dir: %v", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// Create mock worktree directories with .git
-	mockWorktrees := []string{"repo-feature", "repo-bugfix"}
-	for _, wt := range mockWorktrees {
-		wtPath := filepath.Join(tmpDir, wt)
-		gitPath := filepath.Join(wtPath, ".git")
-		if err := os.MkdirAll(gitPath, 0755); err != nil {
-			t.Fatalf("failed to create mock worktree: %v", err)
-		}
+	// Create a repository with worktrees under the directory
+	repo := filepath.Join(t.TempDir(), "repo")
+	runGit(t, "", "init", "-q", repo)
+	runGit(t, repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "initial")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feature/x", filepath.Join(tmpDir, "repo-feature"))
+	runGit(t, repo, "worktree", "add", "-q", "--detach", filepath.Join(tmpDir, "repo-bugfix"))
+
+	// A directory that looks like a worktree but has no git metadata
+	stalePath := filepath.Join(tmpDir, "repo-stale", ".git")
+	if err := os.MkdirAll(stalePath, 0755); err != nil {
+		t.Fatalf("failed to create stale dir: %v", err)
 	}
 
 	// Create a non-worktree directory (no .git)
@@ -171,37 +123,319 @@ func TestListWorktrees_WithMockWorktrees(t *testing.T) {
 		t.Fatalf("failed to create non-worktree dir: %v", err)
 	}
 
-	// Set WT_HOME to temp directory
-	original := os.Getenv("WT_HOME")
-	os.Setenv("WT_HOME", tmpDir)
-	defer func() {
-		if original != "" {
-			os.Setenv("WT_HOME", original)
-		} else {
-			os.Unsetenv("WT_HOME")
-		}
-	}()
-
-	worktrees, err := ListWorktrees()
+	worktrees, err := ListWorktreesIn(tmpDir)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if len(worktrees) != 2 {
-		t.Errorf("expected 2 worktrees, got %d", len(worktrees))
+	// The main worktree of the repository is included too
+	if len(worktrees) != 3 {
+		t.Fatalf("expected 3 worktrees, got %d: %+v", len(worktrees), worktrees)
 	}
 
-	// Verify we got the right worktrees
-	found := make(map[string]bool)
+	found := make(map[string]Worktree)
 	for _, wt := range worktrees {
-		found[wt] = true
+		found[wt.Name] = wt
 	}
-	for _, expected := range mockWorktrees {
-		if !found[expected] {
-			t.Errorf("expected worktree '%s' not found", expected)
+	if wt, ok := found["repo-feature"]; !ok || wt.Branch != "feature/x" || wt.Main {
+		t.Errorf("unexpected repo-feature entry: %+v", wt)
+	}
+	if wt, ok := found["repo-bugfix"]; !ok || !wt.Detached || wt.Branch != "" {
+		t.Errorf("unexpected repo-bugfix entry: %+v", wt)
+	}
+	if wt, ok := found["repo"]; !ok || !wt.Main {
+		t.Errorf("expected main worktree 'repo', got: %+v", worktrees)
+	}
+	for _, wt := range worktrees {
+		if wt.Repo != found["repo"].Path {
+			t.Errorf("expected repo %s for %s, got %s", found["repo"].Path, wt.Name, wt.Repo)
 		}
 	}
-	if found["not-a-worktree"] {
+	if _, ok := found["repo-stale"]; ok {
+		t.Error("stale directory should not be listed")
+	}
+	if _, ok := found["not-a-worktree"]; ok {
 		t.Error("non-worktree directory should not be listed")
 	}
 }
+
+func TestParseWorktreeList(t *testing.T) {
+	input := `worktree /src/repo
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /wt/repo-feature
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/feature/login
+locked in use by CI
+
+worktree /wt/repo-detached
+HEAD 3333333333333333333333333333333333333333
+detached
+
+worktree /wt/repo-gone
+HEAD 4444444444444444444444444444444444444444
+branch refs/heads/gone
+prunable gitdir file points to non-existent location
+
+`
+	worktrees, err := ParseWorktreeList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(worktrees) != 4 {
+		t.Fatalf("expected 4 worktrees, got %d", len(worktrees))
+	}
+
+	mainWt := worktrees[0]
+	if !mainWt.Main || mainWt.Branch != "main" || mainWt.Head != "1111111111111111111111111111111111111111" {
+		t.Errorf("unexpected main worktree: %+v", mainWt)
+	}
+	if wt := worktrees[1]; wt.Main || wt.Branch != "feature/login" || !wt.Locked || wt.LockReason != "in use by CI" {
+		t.Errorf("unexpected locked worktree: %+v", wt)
+	}
+	if wt := worktrees[2]; !wt.Detached || wt.Branch != "" {
+		t.Errorf("unexpected detached worktree: %+v", wt)
+	}
+	if wt := worktrees[3]; !wt.Prunable || wt.PrunableReason != "gitdir file points to non-existent location" {
+		t.Errorf("unexpected prunable worktree: %+v", wt)
+	}
+	for _, wt := range worktrees {
+		if wt.Repo != "/src/repo" {
+			t.Errorf("expected repo /src/repo for %s, got %s", wt.Path, wt.Repo)
+		}
+	}
+}
+
+func TestParseWorktreeList_Bare(t *testing.T) {
+	input := "worktree /src/repo.git\nbare\n\nworktree /wt/x\nHEAD abc\nbranch refs/heads/x\n"
+	worktrees, err := ParseWorktreeList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(worktrees) != 2 || !worktrees[0].Bare || worktrees[1].Repo != "/src/repo.git" {
+		t.Errorf("unexpected worktrees: %+v", worktrees)
+	}
+}
+
+func TestVerifyRef(t *testing.T) {
+	// This test assumes we're running in a git repository
+	if _, err := GetRepoRoot(); err != nil {
+		t.Skipf("skipping test, not in a git repository: %v", err)
+	}
+
+	if err := VerifyRef("HEAD"); err != nil {
+		t.Errorf("expected HEAD to be a valid ref, got: %v", err)
+	}
+
+	err := VerifyRef("refs/heads/does-not-exist-xyz")
+	if err == nil {
+		t.Fatal("expected error for non-existent ref")
+	}
+	if !strings.Contains(err.Error(), "does not exist") || !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("expected 'does not exist' error matching ErrRefNotFound, got: %v", err)
+	}
+}
+
+func TestRemoveWorktree_Errors(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "repo")
+	runGit(t, "", "init", "-q", repo)
+	runGit(t, repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "initial")
+	wtPath := filepath.Join(t.TempDir(), "repo-x")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "x", wtPath)
+
+	if _, err := ResolveCommit(repo, "refs/heads/missing"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("expected ErrRefNotFound for a missing branch, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(wtPath, "notes.txt"), []byte("todo\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := RemoveWorktree(repo, wtPath, false); !errors.Is(err, ErrDirty) || errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrDirty for untracked files, got %v", err)
+	}
+
+	runGit(t, repo, "worktree", "lock", "--reason", "in use", wtPath)
+	if err := RemoveWorktree(repo, wtPath, true); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked for a locked worktree, got %v", err)
+	}
+	if _, err := os.Stat(wtPath); err != nil {
+		t.Errorf("expected the worktree to be kept: %v", err)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	output := `# branch.oid 1111111111111111111111111111111111111111
+# branch.head feature
+# branch.upstream origin/feature
+# branch.ab +2 -1
+1 .M N... 100644 100644 100644 abc abc file.go
+2 R. N... 100644 100644 100644 abc abc R100 new.go	old.go
+u UU N... 100644 100644 100644 100644 a b c conflict.go
+? untracked.txt
+`
+	var status Status
+	if err := parseStatus([]byte(output), &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status.Upstream != "origin/feature" || status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("unexpected upstream info: %+v", status)
+	}
+	if status.Changed != 3 || status.Untracked != 1 || !status.Dirty() {
+		t.Errorf("unexpected change counts: %+v", status)
+	}
+}
+
+func TestListRefs(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "repo")
+	runGit(t, "", "init", "-q", "-b", "main", repo)
+	runGit(t, repo, "-c", "user.name=wt", "-c", "user.email=wt@example.com",
+		"commit", "-q", "--allow-empty", "-m", "initial")
+	runGit(t, repo, "branch", "feature/x")
+	runGit(t, repo, "update-ref", "refs/remotes/origin/main", "HEAD")
+	runGit(t, repo, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main")
+
+	originalDir, _ := os.Getwd()
+	defer func() { _ = os.Chdir(originalDir) }()
+	if err := os.Chdir(repo); err != nil {
+		t.Fatalf("failed to change directory: %v", err)
+	}
+
+	local, err := ListRefs("refs/heads/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(local, " ") != "feature/x main" {
+		t.Errorf("local branches = %v, want [feature/x main]", local)
+	}
+
+	// origin/HEAD is a symbolic ref and left out
+	remote, err := ListRefs("refs/remotes/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(remote, " ") != "origin/main" {
+		t.Errorf("remote branches = %v, want [origin/main]", remote)
+	}
+}
+
+func TestGetUnsavedWork(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "repo")
+	runGit(t, "", "init", "-q", "-b", "main", repo)
+	commit := []string{"-c", "user.name=wt", "-c", "user.email=wt@example.com", "commit", "-q"}
+	if err := os.WriteFile(filepath.Join(repo, "file.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, repo, "add", "file.txt")
+	runGit(t, repo, append(commit, "-m", "initial")...)
+	wtPath := filepath.Join(t.TempDir(), "repo-feature")
+	runGit(t, repo, "worktree", "add", "-q", "-b", "feature", wtPath)
+
+	// A new branch at main's commit has nothing of its own
+	work, err := GetUnsavedWork(wtPath, "feature")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !work.Empty() {
+		t.Errorf("expected no unsaved work, got %+v", work)
+	}
+
+	runGit(t, wtPath, append(commit, "--allow-empty", "-m", "local work")...)
+	if err := os.WriteFile(filepath.Join(wtPath, "file.txt"), []byte("two\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	runGit(t, wtPath, "-c", "user.name=wt", "-c", "user.email=wt@example.com", "stash", "-q")
+	if err := os.WriteFile(filepath.Join(wtPath, "file.txt"), []byte("three\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(wtPath, "new.txt"), nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	work, err = GetUnsavedWork(wtPath, "feature")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := UnsavedWork{Changed: 1, Untracked: 1, Stashes: 1, Unpushed: 1}
+	if work != want {
+		t.Errorf("got %+v, want %+v", work, want)
+	}
+
+	// Once on a remote, the commit is safe
+	runGit(t, repo, "update-ref", "refs/remotes/origin/feature", "feature")
+	work, _ = GetUnsavedWork(wtPath, "feature")
+	if work.Unpushed != 0 {
+		t.Errorf("expected pushed commits not to count, got %d", work.Unpushed)
+	}
+}
+
+func TestIsMergedAndDeleteBranch(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "repo")
+	runGit(t, "", "init", "-q", "-b", "main", repo)
+	commit := []string{"-c", "user.name=wt", "-c", "user.email=wt@example.com", "commit", "-q", "--allow-empty"}
+	runGit(t, repo, append(commit, "-m", "initial")...)
+	runGit(t, repo, "branch", "merged")
+	runGit(t, repo, "checkout", "-q", "-b", "unmerged")
+	runGit(t, repo, append(commit, "-m", "local work")...)
+	runGit(t, repo, "checkout", "-q", "main")
+
+	if head, err := HeadBranch(repo); err != nil || head != "main" {
+		t.Errorf("HeadBranch() = %q, %v, want main", head, err)
+	}
+	if merged, err := IsMerged(repo, "merged", "main"); err != nil || !merged {
+		t.Errorf("IsMerged(merged) = %v, %v, want true", merged, err)
+	}
+	if merged, err := IsMerged(repo, "unmerged", "main"); err != nil || merged {
+		t.Errorf("IsMerged(unmerged) = %v, %v, want false", merged, err)
+	}
+	if _, err := IsMerged(repo, "missing", "main"); err == nil {
+		t.Error("expected an error for a missing branch")
+	}
+
+	if err := DeleteBranch(repo, "unmerged"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := exec.Command("git", "-C", repo, "rev-parse", "--verify", "-q", "refs/heads/unmerged").Run(); err == nil {
+		t.Error("expected branch 'unmerged' to be deleted")
+	}
+}
+
+func TestBranchMovedAndGoneBranches(t *testing.T) {
+	upstream := filepath.Join(t.TempDir(), "upstream")
+	runGit(t, "", "init", "-q", "-b", "main", upstream)
+	commit := []string{"-c", "user.name=wt", "-c", "user.email=wt@example.com", "commit", "-q", "--allow-empty"}
+	runGit(t, upstream, append(commit, "-m", "initial")...)
+	repo := filepath.Join(t.TempDir(), "repo")
+	runGit(t, "", "clone", "-q", upstream, repo)
+
+	runGit(t, repo, "branch", "fresh")
+	runGit(t, repo, "checkout", "-q", "-b", "feature")
+	runGit(t, repo, append(commit, "-m", "feature")...)
+	runGit(t, repo, "push", "-q", "-u", "origin", "feature")
+
+	if moved, err := BranchMoved(repo, "fresh"); err != nil || moved {
+		t.Errorf("BranchMoved(fresh) = %v, %v, want false", moved, err)
+	}
+	if moved, err := BranchMoved(repo, "feature"); err != nil || !moved {
+		t.Errorf("BranchMoved(feature) = %v, %v, want true", moved, err)
+	}
+
+	if _, err := ResolveCommit(repo, "origin/feature"); err != nil {
+		t.Errorf("unexpected error resolving origin/feature: %v", err)
+	}
+	if _, err := ResolveCommit(repo, "origin/missing"); err == nil {
+		t.Error("expected an error resolving a missing ref")
+	}
+
+	runGit(t, upstream, "branch", "-q", "-D", "feature")
+	runGit(t, repo, "fetch", "-q", "--prune")
+	gone, err := GoneBranches(repo)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gone["feature"] || len(gone) != 1 {
+		t.Errorf("expected only feature to be gone, got %v", gone)
+	}
+}
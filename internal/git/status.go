@@ -0,0 +1,164 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status summarizes the state of a worktree
+type Status struct {
+	// Upstream is the branch's upstream, empty if it has none
+	Upstream string
+	Ahead    int
+	Behind   int
+	// Changed counts tracked files with staged or unstaged changes
+	Changed int
+	// Untracked counts untracked files
+	Untracked int
+	// Subject and CommitTime describe the last commit, and are empty in
+	// a repository without commits
+	Subject    string
+	CommitTime time.Time
+}
+
+// Dirty reports whether the worktree has uncommitted or untracked changes
+func (s Status) Dirty() bool {
+	return s.Changed > 0 || s.Untracked > 0
+}
+
+// GetStatus returns the status of the worktree at path
+func GetStatus(path string) (Status, error) {
+	var status Status
+
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain=v2", "--branch")
+	output, err := cmd.Output()
+	if err != nil {
+		return status, fmt.Errorf("failed to get status of %s: %w", path, err)
+	}
+	if err := parseStatus(output, &status); err != nil {
+		return status, err
+	}
+
+	// %x00 separates the fields since subjects can contain anything
+	cmd = exec.Command("git", "-C", path, "log", "-1", "--format=%ct%x00%s")
+	output, err = cmd.Output()
+	if err != nil {
+		// A repository without commits has no last commit
+		return status, nil
+	}
+	timestamp, subject, _ := strings.Cut(strings.TrimRight(string(output), "\n"), "\x00")
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		status.CommitTime = time.Unix(seconds, 0)
+	}
+	status.Subject = subject
+
+	return status, nil
+}
+
+// parseStatus parses the output of git status --porcelain=v2 --branch
+func parseStatus(output []byte, status *Status) error {
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "# branch.upstream "):
+			status.Upstream = strings.TrimPrefix(line, "# branch.upstream ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			// # branch.ab +<ahead> -<behind>
+			fields := strings.Fields(strings.TrimPrefix(line, "# branch.ab "))
+			if len(fields) == 2 {
+				status.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[0], "+"))
+				status.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "-"))
+			}
+		case strings.HasPrefix(line, "1 "), strings.HasPrefix(line, "2 "), strings.HasPrefix(line, "u "):
+			status.Changed++
+		case strings.HasPrefix(line, "? "):
+			status.Untracked++
+		}
+	}
+	return scanner.Err()
+}
+
+// RecentCommits returns up to n one-line summaries of the latest commits
+// in the worktree at path, newest first
+func RecentCommits(path string, n int) ([]string, error) {
+	cmd := exec.Command("git", "-C", path, "log", fmt.Sprintf("-%d", n), "--format=%h %s (%cr)")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commits of %s: %w", path, err)
+	}
+	trimmed := strings.TrimRight(string(output), "\n")
+	if trimmed == "" {
+		return nil, nil
+	}
+	return strings.Split(trimmed, "\n"), nil
+}
+
+// UnsavedWork describes work in a worktree that removing it could lose
+type UnsavedWork struct {
+	// Changed counts tracked files with staged or unstaged changes
+	Changed int
+	// Untracked counts untracked files, not counting ignored ones
+	Untracked int
+	// Stashes counts the stash entries made on the worktree's branch
+	Stashes int
+	// Unpushed counts the commits checked out in the worktree that are on
+	// no remote-tracking branch and no other local branch
+	Unpushed int
+}
+
+// Empty reports whether there is no unsaved work
+func (w UnsavedWork) Empty() bool {
+	return w == UnsavedWork{}
+}
+
+// GetUnsavedWork inspects the worktree at path, which has branch checked
+// out (empty if detached), for work that removing it could lose
+func GetUnsavedWork(path, branch string) (UnsavedWork, error) {
+	var work UnsavedWork
+
+	var status Status
+	cmd := exec.Command("git", "-C", path, "status", "--porcelain=v2")
+	output, err := cmd.Output()
+	if err != nil {
+		return work, fmt.Errorf("failed to get status of %s: %w", path, err)
+	}
+	if err := parseStatus(output, &status); err != nil {
+		return work, err
+	}
+	work.Changed, work.Untracked = status.Changed, status.Untracked
+
+	// Commits only this worktree's branch or detached HEAD has: on no
+	// remote and no other branch
+	args := []string{"-C", path, "rev-list", "--count", "HEAD", "--not", "--remotes"}
+	if branch != "" {
+		args = append(args, "--exclude="+branch)
+	}
+	args = append(args, "--branches")
+	output, err = exec.Command("git", args...).Output()
+	if err != nil {
+		return work, fmt.Errorf("failed to count unpushed commits in %s: %w", path, err)
+	}
+	work.Unpushed, _ = strconv.Atoi(strings.TrimSpace(string(output)))
+
+	// Stashes belong to the repository, but their subjects name the
+	// branch they were made on: "WIP on <branch>: ..." or "On <branch>: ..."
+	if branch != "" {
+		output, err = exec.Command("git", "-C", path, "stash", "list", "--format=%gs").Output()
+		if err != nil {
+			return work, fmt.Errorf("failed to list stashes in %s: %w", path, err)
+		}
+		for _, subject := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(subject, "WIP on "+branch+":") || strings.HasPrefix(subject, "On "+branch+":") {
+				work.Stashes++
+			}
+		}
+	}
+
+	return work, nil
+}
@@ -0,0 +1,220 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Worktree describes a worktree as reported by git worktree list --porcelain
+type Worktree struct {
+	// Name is the worktree's path relative to the directory it was
+	// discovered in, or its directory name if it lives elsewhere
+	Name string
+	// Path is the absolute path of the worktree
+	Path string
+	// Repo is the path of the repository's main worktree (or the bare
+	// repository) the worktree belongs to
+	Repo string
+	// Head is the commit checked out in the worktree
+	Head string
+	// Branch is the checked-out branch, empty if detached
+	Branch string
+	// Main is set for the repository's main worktree
+	Main     bool
+	Bare     bool
+	Detached bool
+	Locked   bool
+	// LockReason is the reason given to git worktree lock, if any
+	LockReason string
+	// Prunable is set when git would prune the worktree's metadata,
+	// typically because its directory no longer exists
+	Prunable       bool
+	PrunableReason string
+}
+
+// ParseWorktreeList parses the output of git worktree list --porcelain.
+// The first entry is the repository's main worktree.
+func ParseWorktreeList(r io.Reader) ([]Worktree, error) {
+	var worktrees []Worktree
+	var current *Worktree
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			current = nil
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		if key == "worktree" {
+			worktrees = append(worktrees, Worktree{Path: value})
+			current = &worktrees[len(worktrees)-1]
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("unexpected line in worktree list: %q", line)
+		}
+
+		switch key {
+		case "HEAD":
+			current.Head = value
+		case "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "bare":
+			current.Bare = true
+		case "detached":
+			current.Detached = true
+		case "locked":
+			current.Locked = true
+			current.LockReason = value
+		case "prunable":
+			current.Prunable = true
+			current.PrunableReason = value
+		}
+		// Unknown attributes are ignored so newer git versions keep working
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read worktree list: %w", err)
+	}
+
+	if len(worktrees) > 0 {
+		repo := worktrees[0].Path
+		worktrees[0].Main = true
+		for i := range worktrees {
+			worktrees[i].Repo = repo
+		}
+	}
+	return worktrees, nil
+}
+
+// ListRepoWorktrees returns all worktrees of the repository containing dir
+func ListRepoWorktrees(dir string) ([]Worktree, error) {
+	cmd := exec.Command("git", "-C", dir, "worktree", "list", "--porcelain")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees of %s: %s", dir, strings.TrimSpace(stderr.String()))
+	}
+	return ParseWorktreeList(bytes.NewReader(output))
+}
+
+// ListWorktreesIn returns the worktrees of every repository that has a
+// worktree directly under wtHome
+func ListWorktreesIn(wtHome string) ([]Worktree, error) {
+	return ListWorktreesGlob(wtHome, filepath.Join(wtHome, "*"))
+}
+
+// ListWorktreesGlob returns the worktrees of every repository that has a
+// worktree matching the filepath.Glob pattern, merged across repositories.
+// Directories whose git metadata is gone are ignored. Worktree names are
+// their paths relative to root when they live under it.
+func ListWorktreesGlob(root, pattern string) ([]Worktree, error) {
+	var worktrees []Worktree
+	if _, err := os.Stat(root); err != nil {
+		if os.IsNotExist(err) {
+			return worktrees, nil
+		}
+		return nil, fmt.Errorf("failed to read WT_HOME directory: %w", err)
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid worktree pattern %s: %w", pattern, err)
+	}
+
+	// Find the repositories the candidate directories belong to
+	var repos []string
+	seenRepos := make(map[string]bool)
+	for _, candidate := range paths {
+		commonDir, err := CommonDir(candidate)
+		if err != nil {
+			continue
+		}
+		if !seenRepos[commonDir] {
+			seenRepos[commonDir] = true
+			repos = append(repos, commonDir)
+		}
+	}
+	sort.Strings(repos)
+
+	seenPaths := make(map[string]bool)
+	for _, repo := range repos {
+		repoWorktrees, err := ListRepoWorktrees(repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, wt := range repoWorktrees {
+			if wt.Bare || seenPaths[wt.Path] {
+				continue
+			}
+			seenPaths[wt.Path] = true
+			wt.Name = worktreeName(root, wt.Path)
+			worktrees = append(worktrees, wt)
+		}
+	}
+
+	return worktrees, nil
+}
+
+// worktreeName returns path relative to root if it is inside root,
+// otherwise its directory name
+func worktreeName(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// CommonDir returns the repository git directory shared by all worktrees
+// of the repository that dir is a worktree of. It reads the .git entry
+// directly rather than running git so scanning WT_HOME stays cheap, and
+// returns an error if dir is not a worktree or its metadata is gone.
+func CommonDir(dir string) (string, error) {
+	gitPath := filepath.Join(dir, ".git")
+	info, err := os.Stat(gitPath)
+	if err != nil {
+		return "", fmt.Errorf("not a git worktree: %s", dir)
+	}
+	if info.IsDir() {
+		// A main worktree; make sure it's a real repository
+		if _, err := os.Stat(filepath.Join(gitPath, "HEAD")); err != nil {
+			return "", fmt.Errorf("not a git worktree: %s", dir)
+		}
+		return gitPath, nil
+	}
+
+	// Linked worktrees have a .git file pointing at their git directory,
+	// which has a commondir file pointing back at the repository
+	data, err := os.ReadFile(gitPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", gitPath, err)
+	}
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("invalid .git file: %s", gitPath)
+	}
+	gitDir := strings.TrimPrefix(line, "gitdir: ")
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+
+	common, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return "", fmt.Errorf("worktree metadata missing for %s", dir)
+	}
+	commonDir := strings.TrimSpace(string(common))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir), nil
+}
@@ -0,0 +1,223 @@
+// Package history records which worktrees the user visits, so navigation
+// can rank frequently and recently used worktrees first.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileName is the name of the history file in the data directory
+const FileName = "history.json"
+
+const (
+	// maxVisits is how many visit times are kept per worktree
+	maxVisits = 10
+	// maxTotalCount bounds the sum of all visit counts. When it is
+	// exceeded every count is scaled down, so old favorites fade out as
+	// new ones are used.
+	maxTotalCount = 1000
+	// agingFactor scales counts down when maxTotalCount is exceeded
+	agingFactor = 0.9
+	// maxAge drops worktrees that haven't been visited for this long
+	maxAge = 180 * 24 * time.Hour
+)
+
+// Entry is the visit record of one worktree
+type Entry struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	// Count is the number of visits, scaled down as the history ages
+	Count float64 `json:"count"`
+	// Visits holds the most recent visit times, oldest first
+	Visits []time.Time `json:"visits"`
+}
+
+// LastVisit returns the time of the most recent visit
+func (e Entry) LastVisit() time.Time {
+	if len(e.Visits) == 0 {
+		return time.Time{}
+	}
+	return e.Visits[len(e.Visits)-1]
+}
+
+// Frecency scores how frequently and recently the worktree was visited.
+// Each recent visit is weighted by its age, and the average weight is
+// scaled by the visit count.
+func (e Entry) Frecency(now time.Time) float64 {
+	if len(e.Visits) == 0 {
+		return 0
+	}
+	total := 0.0
+	for _, visit := range e.Visits {
+		total += recencyWeight(now.Sub(visit))
+	}
+	return e.Count * total / float64(len(e.Visits))
+}
+
+// recencyWeight weighs a visit by how long ago it was
+func recencyWeight(age time.Duration) float64 {
+	switch {
+	case age < time.Hour:
+		return 100
+	case age < 24*time.Hour:
+		return 80
+	case age < 7*24*time.Hour:
+		return 60
+	case age < 30*24*time.Hour:
+		return 40
+	case age < 90*24*time.Hour:
+		return 20
+	default:
+		return 10
+	}
+}
+
+// History is the set of visited worktrees, stored as JSON in a file
+type History struct {
+	path    string
+	entries map[string]*Entry
+}
+
+// file is the on-disk format of the history
+type file struct {
+	Version int      `json:"version"`
+	Entries []*Entry `json:"entries"`
+}
+
+// Load reads the history from dir. A missing file is an empty history.
+func Load(dir string) (*History, error) {
+	h := &History{path: filepath.Join(dir, FileName), entries: make(map[string]*Entry)}
+
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return h, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return h, fmt.Errorf("invalid history file %s: %w", h.path, err)
+	}
+	for _, e := range f.Entries {
+		if e.Path != "" {
+			h.entries[e.Path] = e
+		}
+	}
+	return h, nil
+}
+
+// Visit records a visit to the worktree at path
+func (h *History) Visit(name, path string, now time.Time) {
+	e, ok := h.entries[path]
+	if !ok {
+		e = &Entry{Path: path}
+		h.entries[path] = e
+	}
+	e.Name = name
+	e.Count++
+	e.Visits = append(e.Visits, now)
+	if len(e.Visits) > maxVisits {
+		e.Visits = e.Visits[len(e.Visits)-maxVisits:]
+	}
+	h.age()
+}
+
+// age scales all counts down once their sum exceeds maxTotalCount,
+// dropping worktrees whose count falls below one visit
+func (h *History) age() {
+	total := 0.0
+	for _, e := range h.entries {
+		total += e.Count
+	}
+	if total <= maxTotalCount {
+		return
+	}
+	for path, e := range h.entries {
+		e.Count *= agingFactor
+		if e.Count < 1 {
+			delete(h.entries, path)
+		}
+	}
+}
+
+// Forget removes the worktrees whose name or path is name, and returns
+// the removed entries
+func (h *History) Forget(name string) []Entry {
+	var removed []Entry
+	for path, e := range h.entries {
+		if e.Name == name || e.Path == name {
+			removed = append(removed, *e)
+			delete(h.entries, path)
+		}
+	}
+	return removed
+}
+
+// Frecency returns the frecency of the worktree at path, 0 if it was
+// never visited
+func (h *History) Frecency(path string, now time.Time) float64 {
+	if e, ok := h.entries[path]; ok {
+		return e.Frecency(now)
+	}
+	return 0
+}
+
+// Entries returns the recorded worktrees, most recently visited first
+func (h *History) Entries() []Entry {
+	entries := make([]Entry, 0, len(h.entries))
+	for _, e := range h.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastVisit().After(entries[j].LastVisit())
+	})
+	return entries
+}
+
+// Save writes the history back to its file, dropping worktrees that
+// haven't been visited for a long time. The file is replaced atomically
+// so a concurrent reader never sees a partial write.
+func (h *History) Save(now time.Time) error {
+	f := file{Version: 1}
+	for path, e := range h.entries {
+		if now.Sub(e.LastVisit()) > maxAge {
+			delete(h.entries, path)
+			continue
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	sort.Slice(f.Entries, func(i, j int) bool { return f.Entries[i].Path < f.Entries[j].Path })
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), FileName+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
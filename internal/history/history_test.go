@@ -0,0 +1,143 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	h, err := Load(filepath.Join(t.TempDir(), "none"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(h.Entries()) != 0 {
+		t.Errorf("expected an empty history, got %+v", h.Entries())
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte("{"), 0644); err != nil {
+		t.Fatalf("failed to write history: %v", err)
+	}
+	h, err := Load(dir)
+	if err == nil {
+		t.Error("expected an error for a corrupt history file")
+	}
+	if h == nil || len(h.Entries()) != 0 {
+		t.Error("expected an empty history alongside the error")
+	}
+}
+
+func TestVisit_SaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested")
+	now := time.Now()
+
+	h, _ := Load(dir)
+	h.Visit("api", "/trees/api", now.Add(-time.Hour))
+	h.Visit("api", "/trees/api", now)
+	h.Visit("web", "/trees/web", now.Add(-time.Minute))
+	if err := h.Save(now); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	h, err := Load(dir)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	entries := h.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Name != "api" || entries[0].Count != 2 || len(entries[0].Visits) != 2 {
+		t.Errorf("expected api visited twice most recently, got %+v", entries[0])
+	}
+	if !entries[0].LastVisit().Equal(now) {
+		t.Errorf("expected last visit %v, got %v", now, entries[0].LastVisit())
+	}
+}
+
+func TestVisit_KeepsRecentVisits(t *testing.T) {
+	h, _ := Load(t.TempDir())
+	now := time.Now()
+	for i := 0; i < maxVisits+5; i++ {
+		h.Visit("api", "/trees/api", now.Add(time.Duration(i)*time.Second))
+	}
+	e := h.Entries()[0]
+	if len(e.Visits) != maxVisits {
+		t.Errorf("expected %d visits kept, got %d", maxVisits, len(e.Visits))
+	}
+	if e.Count != maxVisits+5 {
+		t.Errorf("expected count %d, got %v", maxVisits+5, e.Count)
+	}
+}
+
+func TestFrecency(t *testing.T) {
+	now := time.Now()
+	recent := Entry{Count: 2, Visits: []time.Time{now.Add(-time.Minute), now}}
+	old := Entry{Count: 2, Visits: []time.Time{now.Add(-60 * 24 * time.Hour), now.Add(-50 * 24 * time.Hour)}}
+	frequent := Entry{Count: 20, Visits: []time.Time{now.Add(-60 * 24 * time.Hour), now.Add(-50 * 24 * time.Hour)}}
+
+	if recent.Frecency(now) <= old.Frecency(now) {
+		t.Errorf("expected recent visits to score higher: recent=%v old=%v", recent.Frecency(now), old.Frecency(now))
+	}
+	if frequent.Frecency(now) <= old.Frecency(now) {
+		t.Errorf("expected frequent visits to score higher: frequent=%v old=%v", frequent.Frecency(now), old.Frecency(now))
+	}
+	if (Entry{}).Frecency(now) != 0 {
+		t.Error("expected no frecency without visits")
+	}
+}
+
+func TestAging(t *testing.T) {
+	h, _ := Load(t.TempDir())
+	now := time.Now()
+	h.Visit("rare", "/trees/rare", now)
+	for i := 0; i < maxTotalCount; i++ {
+		h.Visit("busy", "/trees/busy", now)
+	}
+
+	// The total passed maxTotalCount, so counts were scaled down and the
+	// rarely used worktree dropped out
+	entries := h.Entries()
+	if len(entries) != 1 || entries[0].Name != "busy" {
+		t.Fatalf("expected only busy to remain, got %+v", entries)
+	}
+	if entries[0].Count >= maxTotalCount {
+		t.Errorf("expected busy's count to be scaled down, got %v", entries[0].Count)
+	}
+}
+
+func TestSave_DropsOldEntries(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	h, _ := Load(dir)
+	h.Visit("stale", "/trees/stale", now.Add(-maxAge-time.Hour))
+	h.Visit("fresh", "/trees/fresh", now)
+	if err := h.Save(now); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	h, _ = Load(dir)
+	if entries := h.Entries(); len(entries) != 1 || entries[0].Name != "fresh" {
+		t.Errorf("expected only fresh to remain, got %+v", entries)
+	}
+}
+
+func TestForget(t *testing.T) {
+	h, _ := Load(t.TempDir())
+	h.Visit("api", "/trees/api", time.Now())
+	h.Visit("web", "/trees/web", time.Now())
+
+	if removed := h.Forget("/trees/api"); len(removed) != 1 || removed[0].Name != "api" {
+		t.Errorf("expected to forget api by path, got %+v", removed)
+	}
+	if removed := h.Forget("web"); len(removed) != 1 {
+		t.Errorf("expected to forget web by name, got %+v", removed)
+	}
+	if removed := h.Forget("web"); len(removed) != 0 {
+		t.Errorf("expected nothing left to forget, got %+v", removed)
+	}
+}
@@ -0,0 +1,496 @@
+// Package picker implements an fzf-style full-screen fuzzy picker for
+// choosing one or more items from a list in the terminal. The query uses
+// fzf's syntax, see fuzzy.Query.
+package picker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/niczy/wt/internal/fuzzy"
+)
+
+// ErrCancelled is returned when the user dismisses the picker
+var ErrCancelled = errors.New("selection cancelled")
+
+// ErrUnavailable is returned when the terminal can't run the picker, in
+// which case callers should fall back to a plain prompt
+var ErrUnavailable = errors.New("interactive picker unavailable")
+
+// Options configures a picker
+type Options struct {
+	// Items are the labels to choose from, best match first
+	Items []string
+	// Prompt is shown before the query
+	Prompt string
+	// Preview returns the text shown below the list for the highlighted
+	// item. It may be nil.
+	Preview func(index int) string
+	// Color highlights matched characters in color; otherwise they are
+	// underlined
+	Color bool
+	// Literal makes the query match accented letters only exactly, see
+	// fuzzy.Query
+	Literal bool
+	// Multi lets the user mark several items with Tab, see RunMulti
+	Multi bool
+}
+
+// Available reports whether stdin and stderr are both terminals
+func Available() bool {
+	return IsTerminal(os.Stdin) && IsTerminal(os.Stderr)
+}
+
+// IsTerminal reports whether f is a character device such as a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Run shows the picker on stderr, reading keys from stdin, and returns
+// the index of the chosen item
+func Run(opts Options) (int, error) {
+	opts.Multi = false
+	indices, err := RunMulti(opts)
+	if err != nil {
+		return -1, err
+	}
+	return indices[0], nil
+}
+
+// RunMulti is Run for choosing several items: with opts.Multi set, Tab
+// marks and unmarks the highlighted item, and Enter returns the indices of
+// the marked items in list order, or of the highlighted one if none are
+// marked
+func RunMulti(opts Options) ([]int, error) {
+	if !Available() {
+		return nil, ErrUnavailable
+	}
+	term, err := openTerminal()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer term.restore()
+
+	// Use the alternate screen so the picker doesn't clobber scrollback
+	fmt.Fprint(os.Stderr, "\x1b[?1049h")
+	defer fmt.Fprint(os.Stderr, "\x1b[?1049l")
+
+	s := newState(opts.Items)
+	s.literal = opts.Literal
+	s.multi = opts.Multi
+	s.refilter()
+	previews := make(map[int]string)
+	preview := func(index int) string {
+		if opts.Preview == nil {
+			return ""
+		}
+		if text, ok := previews[index]; ok {
+			return text
+		}
+		text := opts.Preview(index)
+		previews[index] = text
+		return text
+	}
+
+	buf := make([]byte, 256)
+	for {
+		rows, cols := term.size()
+		var frame bytes.Buffer
+		s.render(&frame, opts, rows, cols, preview)
+		if _, err := os.Stderr.Write(frame.Bytes()); err != nil {
+			return nil, err
+		}
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			switch s.handleKey(k) {
+			case actionAccept:
+				if indices := s.chosen(); len(indices) > 0 {
+					return indices, nil
+				}
+			case actionCancel:
+				return nil, ErrCancelled
+			}
+		}
+	}
+}
+
+// keyKind identifies a key press
+type keyKind int
+
+const (
+	keyRune keyKind = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyEnter
+	keyCancel
+	keyBackspace
+	keyClearQuery
+	keyDeleteWord
+	keyToggle
+	keyIgnored
+)
+
+type key struct {
+	kind keyKind
+	r    rune
+}
+
+// parseKeys decodes raw terminal input into key presses
+func parseKeys(input []byte) []key {
+	var keys []key
+	for len(input) > 0 {
+		switch c := input[0]; {
+		case c == 0x1b:
+			if len(input) == 1 {
+				// A lone escape cancels
+				keys = append(keys, key{kind: keyCancel})
+				input = input[1:]
+				continue
+			}
+			k, size := parseEscape(input)
+			keys = append(keys, k)
+			input = input[size:]
+		case c == '\r':
+			keys = append(keys, key{kind: keyEnter})
+			input = input[1:]
+		case c == '\t':
+			keys = append(keys, key{kind: keyToggle})
+			input = input[1:]
+		case c == 0x03 || c == 0x07: // ctrl-c, ctrl-g
+			keys = append(keys, key{kind: keyCancel})
+			input = input[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, key{kind: keyBackspace})
+			input = input[1:]
+		case c == 0x10 || c == 0x0b: // ctrl-p, ctrl-k
+			keys = append(keys, key{kind: keyUp})
+			input = input[1:]
+		case c == 0x0e || c == 0x0a: // ctrl-n, ctrl-j
+			keys = append(keys, key{kind: keyDown})
+			input = input[1:]
+		case c == 0x15: // ctrl-u
+			keys = append(keys, key{kind: keyClearQuery})
+			input = input[1:]
+		case c == 0x17: // ctrl-w
+			keys = append(keys, key{kind: keyDeleteWord})
+			input = input[1:]
+		case c < 0x20:
+			keys = append(keys, key{kind: keyIgnored})
+			input = input[1:]
+		default:
+			r, size := utf8.DecodeRune(input)
+			if r == utf8.RuneError && size <= 1 {
+				keys = append(keys, key{kind: keyIgnored})
+				input = input[1:]
+				continue
+			}
+			keys = append(keys, key{kind: keyRune, r: r})
+			input = input[size:]
+		}
+	}
+	return keys
+}
+
+// parseEscape decodes an escape sequence at the start of input, returning
+// the key and the number of bytes consumed
+func parseEscape(input []byte) (key, int) {
+	if len(input) < 3 || (input[1] != '[' && input[1] != 'O') {
+		// Alt+key or an unknown sequence; drop the escape
+		return key{kind: keyIgnored}, 1
+	}
+	switch input[2] {
+	case 'A':
+		return key{kind: keyUp}, 3
+	case 'B':
+		return key{kind: keyDown}, 3
+	case 'C', 'D', 'H', 'F':
+		return key{kind: keyIgnored}, 3
+	}
+	// CSI sequences such as "\x1b[5~" end in a byte from '@' to '~'
+	for i := 2; i < len(input); i++ {
+		if input[i] >= '@' && input[i] <= '~' {
+			seq := string(input[2 : i+1])
+			switch seq {
+			case "5~":
+				return key{kind: keyPageUp}, i + 1
+			case "6~":
+				return key{kind: keyPageDown}, i + 1
+			}
+			return key{kind: keyIgnored}, i + 1
+		}
+	}
+	return key{kind: keyIgnored}, len(input)
+}
+
+type action int
+
+const (
+	actionNone action = iota
+	actionAccept
+	actionCancel
+)
+
+// state is the picker's query, filtered items and cursor
+type state struct {
+	items    []string
+	query    []rune
+	filtered []int
+	// positions holds the matched rune positions of each filtered item
+	positions [][]int
+	literal   bool
+	// multi allows marking items, and marked holds the marked indices
+	multi  bool
+	marked map[int]bool
+	cursor int
+	offset int
+	// pageSize is the number of visible rows, set when rendering
+	pageSize int
+}
+
+func newState(items []string) *state {
+	s := &state{items: items, pageSize: 10, marked: make(map[int]bool)}
+	s.refilter()
+	return s
+}
+
+// refilter recomputes the visible items for the current query
+func (s *state) refilter() {
+	s.filtered = s.filtered[:0]
+	s.positions = s.positions[:0]
+	query := fuzzy.ParseQuery(string(s.query))
+	query.Literal = s.literal
+	if query.Empty() {
+		for i := range s.items {
+			s.filtered = append(s.filtered, i)
+			s.positions = append(s.positions, nil)
+		}
+	} else {
+		// Map matched texts back to indices, allowing duplicate labels
+		indices := make(map[string][]int)
+		for i, item := range s.items {
+			indices[item] = append(indices[item], i)
+		}
+		for _, m := range query.Filter(s.items) {
+			s.filtered = append(s.filtered, indices[m.Text][0])
+			s.positions = append(s.positions, m.Positions)
+			indices[m.Text] = indices[m.Text][1:]
+		}
+	}
+	s.cursor = 0
+	s.offset = 0
+}
+
+// selected returns the index of the highlighted item
+func (s *state) selected() (int, bool) {
+	if len(s.filtered) == 0 {
+		return -1, false
+	}
+	return s.filtered[s.cursor], true
+}
+
+// chosen returns the indices of the marked items in list order, or of the
+// highlighted item if none are marked
+func (s *state) chosen() []int {
+	var indices []int
+	for i := range s.items {
+		if s.marked[i] {
+			indices = append(indices, i)
+		}
+	}
+	if len(indices) > 0 {
+		return indices
+	}
+	if index, ok := s.selected(); ok {
+		return []int{index}
+	}
+	return nil
+}
+
+// handleKey applies a key press to the state
+func (s *state) handleKey(k key) action {
+	switch k.kind {
+	case keyRune:
+		s.query = append(s.query, k.r)
+		s.refilter()
+	case keyBackspace:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			s.refilter()
+		}
+	case keyClearQuery:
+		s.query = s.query[:0]
+		s.refilter()
+	case keyDeleteWord:
+		end := len(s.query)
+		for end > 0 && unicode.IsSpace(s.query[end-1]) {
+			end--
+		}
+		for end > 0 && !unicode.IsSpace(s.query[end-1]) {
+			end--
+		}
+		s.query = s.query[:end]
+		s.refilter()
+	case keyUp:
+		s.move(-1)
+	case keyDown:
+		s.move(1)
+	case keyPageUp:
+		s.move(-s.pageSize)
+	case keyPageDown:
+		s.move(s.pageSize)
+	case keyToggle:
+		if index, ok := s.selected(); ok && s.multi {
+			if s.marked[index] {
+				delete(s.marked, index)
+			} else {
+				s.marked[index] = true
+			}
+			s.move(1)
+		}
+	case keyEnter:
+		return actionAccept
+	case keyCancel:
+		return actionCancel
+	}
+	return actionNone
+}
+
+// move moves the cursor by delta rows, keeping it on screen
+func (s *state) move(delta int) {
+	if len(s.filtered) == 0 {
+		return
+	}
+	s.cursor += delta
+	if s.cursor < 0 {
+		s.cursor = 0
+	}
+	if s.cursor >= len(s.filtered) {
+		s.cursor = len(s.filtered) - 1
+	}
+	s.scroll()
+}
+
+// scroll adjusts the offset so the cursor is visible
+func (s *state) scroll() {
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+	if s.pageSize > 0 && s.cursor >= s.offset+s.pageSize {
+		s.offset = s.cursor - s.pageSize + 1
+	}
+}
+
+// render draws the picker: the query line, a match count, the list and,
+// if enabled, a preview of the highlighted item
+func (s *state) render(w *bytes.Buffer, opts Options, rows, cols int, preview func(int) string) {
+	withPreview := opts.Preview != nil
+	listRows := rows - 2
+	previewRows := 0
+	if withPreview {
+		previewRows = (rows - 2) / 2
+		listRows = rows - 3 - previewRows
+	}
+	if listRows < 1 {
+		listRows = 1
+	}
+	s.pageSize = listRows
+	s.scroll()
+
+	w.WriteString("\x1b[H\x1b[2J")
+	w.WriteString(truncate(opts.Prompt+string(s.query), cols))
+	w.WriteString("\r\n")
+	count := fmt.Sprintf("%d/%d", len(s.filtered), len(s.items))
+	if s.multi && len(s.marked) > 0 {
+		count += fmt.Sprintf(" (%d marked, Tab to toggle)", len(s.marked))
+	} else if s.multi {
+		count += " (Tab to mark several)"
+	}
+	w.WriteString(fmt.Sprintf("\x1b[2m  %s\x1b[0m\r\n", count))
+
+	for row := 0; row < listRows; row++ {
+		i := s.offset + row
+		if i < len(s.filtered) {
+			item := s.items[s.filtered[i]]
+			mark := " "
+			if s.marked[s.filtered[i]] {
+				mark = "*"
+			}
+			if i == s.cursor {
+				w.WriteString("\x1b[1m>" + mark)
+				writeHighlighted(w, item, s.positions[i], cols-2, "\x1b[1m", opts.Color)
+			} else {
+				w.WriteString(" " + mark)
+				writeHighlighted(w, item, s.positions[i], cols-2, "", opts.Color)
+			}
+			w.WriteString("\x1b[0m")
+		}
+		w.WriteString("\r\n")
+	}
+
+	if withPreview {
+		w.WriteString("\x1b[2m" + strings.Repeat("─", cols) + "\x1b[0m\r\n")
+		if index, ok := s.selected(); ok {
+			lines := strings.Split(strings.TrimRight(preview(index), "\n"), "\n")
+			for i := 0; i < previewRows && i < len(lines); i++ {
+				w.WriteString(truncate(strings.ReplaceAll(lines[i], "\t", "    "), cols))
+				if i < previewRows-1 {
+					w.WriteString("\r\n")
+				}
+			}
+		}
+	}
+
+	// Leave the cursor at the end of the query
+	w.WriteString(fmt.Sprintf("\x1b[1;%dH", utf8.RuneCountInString(opts.Prompt)+len(s.query)+1))
+}
+
+// writeHighlighted writes text truncated to width runes, with the runes at
+// positions in bold green, or underlined without color. base is re-applied
+// after each highlighted rune.
+func writeHighlighted(w *bytes.Buffer, text string, positions []int, width int, base string, color bool) {
+	highlight := "\x1b[4m"
+	if color {
+		highlight = "\x1b[1;32m"
+	}
+	i := 0
+	for _, r := range text {
+		if i >= width {
+			break
+		}
+		if len(positions) > 0 && positions[0] == i {
+			w.WriteString(highlight)
+			w.WriteRune(r)
+			w.WriteString("\x1b[0m" + base)
+			positions = positions[1:]
+		} else {
+			w.WriteRune(r)
+		}
+		i++
+	}
+}
+
+// truncate shortens s to at most width runes
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
@@ -0,0 +1,194 @@
+package picker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []key
+	}{
+		{"ab", []key{{kind: keyRune, r: 'a'}, {kind: keyRune, r: 'b'}}},
+		{"é", []key{{kind: keyRune, r: 'é'}}},
+		{"\x1b[A\x1b[B", []key{{kind: keyUp}, {kind: keyDown}}},
+		{"\x1bOA", []key{{kind: keyUp}}},
+		{"\x1b[5~\x1b[6~", []key{{kind: keyPageUp}, {kind: keyPageDown}}},
+		{"\x1b", []key{{kind: keyCancel}}},
+		{"\x03", []key{{kind: keyCancel}}},
+		{"\r", []key{{kind: keyEnter}}},
+		{"\x7f\x15\x17", []key{{kind: keyBackspace}, {kind: keyClearQuery}, {kind: keyDeleteWord}}},
+		{"\x10\x0e", []key{{kind: keyUp}, {kind: keyDown}}},
+		{"\x1b[C", []key{{kind: keyIgnored}}},
+		{"\t", []key{{kind: keyToggle}}},
+	}
+
+	for _, tt := range tests {
+		got := parseKeys([]byte(tt.input))
+		if len(got) != len(tt.want) {
+			t.Errorf("parseKeys(%q) = %v, want %v", tt.input, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseKeys(%q)[%d] = %v, want %v", tt.input, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestState_Filter(t *testing.T) {
+	s := newState([]string{"feature-auth", "bugfix", "feature-ui", "bugfix"})
+	if len(s.filtered) != 4 {
+		t.Fatalf("expected all 4 items before typing, got %d", len(s.filtered))
+	}
+
+	for _, r := range "bug" {
+		s.handleKey(key{kind: keyRune, r: r})
+	}
+	// Duplicate labels must map to distinct indices
+	if len(s.filtered) != 2 || s.filtered[0] == s.filtered[1] {
+		t.Fatalf("expected two distinct bugfix items, got %v", s.filtered)
+	}
+	for _, i := range s.filtered {
+		if s.items[i] != "bugfix" {
+			t.Errorf("unexpected item %q", s.items[i])
+		}
+	}
+
+	s.handleKey(key{kind: keyClearQuery})
+	for _, r := range "zzz" {
+		s.handleKey(key{kind: keyRune, r: r})
+	}
+	if _, ok := s.selected(); ok {
+		t.Error("expected no selection when nothing matches")
+	}
+	if s.handleKey(key{kind: keyEnter}) != actionAccept {
+		t.Error("expected enter to accept")
+	}
+
+	s.handleKey(key{kind: keyDeleteWord})
+	if len(s.query) != 0 || len(s.filtered) != 4 {
+		t.Errorf("expected ctrl-w to clear the query, got %q with %d items", string(s.query), len(s.filtered))
+	}
+}
+
+func TestState_Move(t *testing.T) {
+	s := newState([]string{"a", "b", "c", "d", "e"})
+	s.pageSize = 2
+
+	s.handleKey(key{kind: keyUp})
+	if s.cursor != 0 {
+		t.Errorf("expected cursor to stay at 0, got %d", s.cursor)
+	}
+	s.handleKey(key{kind: keyDown})
+	s.handleKey(key{kind: keyDown})
+	if s.cursor != 2 || s.offset != 1 {
+		t.Errorf("expected cursor 2 offset 1, got cursor %d offset %d", s.cursor, s.offset)
+	}
+	s.handleKey(key{kind: keyPageDown})
+	s.handleKey(key{kind: keyPageDown})
+	if index, _ := s.selected(); index != 4 {
+		t.Errorf("expected last item selected, got %d", index)
+	}
+	if s.handleKey(key{kind: keyCancel}) != actionCancel {
+		t.Error("expected escape to cancel")
+	}
+}
+
+func TestState_Multi(t *testing.T) {
+	s := newState([]string{"a", "b", "c"})
+	s.handleKey(key{kind: keyToggle})
+	if got := s.chosen(); len(got) != 1 || got[0] != 0 {
+		t.Errorf("expected Tab to do nothing without multi, got %v", got)
+	}
+
+	s.multi = true
+	s.handleKey(key{kind: keyToggle})
+	s.handleKey(key{kind: keyDown})
+	s.handleKey(key{kind: keyToggle})
+	if got := s.chosen(); len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Errorf("expected a and c marked, got %v", got)
+	}
+
+	// Marks survive filtering, and Tab on a marked item unmarks it
+	for _, r := range "c" {
+		s.handleKey(key{kind: keyRune, r: r})
+	}
+	s.handleKey(key{kind: keyToggle})
+	if got := s.chosen(); len(got) != 1 || got[0] != 0 {
+		t.Errorf("expected only a marked, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	s.render(&buf, Options{Prompt: "> "}, 10, 40, nil)
+	if !strings.Contains(buf.String(), "1 marked") {
+		t.Errorf("expected the marked count, got %q", buf.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	s := newState([]string{"feature-auth", "bugfix"})
+	for _, r := range "fa" {
+		s.handleKey(key{kind: keyRune, r: r})
+	}
+
+	var buf bytes.Buffer
+	preview := func(i int) string { return "preview of " + s.items[i] }
+	s.render(&buf, Options{Prompt: "> ", Preview: preview, Color: true}, 10, 40, preview)
+	out := buf.String()
+
+	if !strings.Contains(out, "> fa") {
+		t.Errorf("expected query line, got %q", out)
+	}
+	if !strings.Contains(out, "1/2") {
+		t.Errorf("expected match count, got %q", out)
+	}
+	if !strings.Contains(out, "preview of feature-auth") {
+		t.Errorf("expected preview, got %q", out)
+	}
+	if !strings.Contains(out, "\x1b[1;32mf\x1b[0m") {
+		t.Errorf("expected highlighted match, got %q", out)
+	}
+}
+
+func TestState_Positions(t *testing.T) {
+	s := newState([]string{"bugfix", "feature-auth"})
+	for _, r := range "fa" {
+		s.handleKey(key{kind: keyRune, r: r})
+	}
+	if len(s.filtered) != 1 || s.items[s.filtered[0]] != "feature-auth" {
+		t.Fatalf("expected only feature-auth to match, got %v", s.filtered)
+	}
+	// The "a" at the start of "auth" beats the one inside "feature"
+	if got := s.positions[0]; len(got) != 2 || got[0] != 0 || got[1] != 8 {
+		t.Errorf("expected positions [0 8], got %v", got)
+	}
+}
+
+func TestWriteHighlighted(t *testing.T) {
+	var buf bytes.Buffer
+	writeHighlighted(&buf, "héllo", []int{1, 4}, 10, "", false)
+	want := "h\x1b[4mé\x1b[0mll\x1b[4mo\x1b[0m"
+	if got := buf.String(); got != want {
+		t.Errorf("writeHighlighted = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	writeHighlighted(&buf, "héllo", []int{1, 4}, 3, "", true)
+	want = "h\x1b[1;32mé\x1b[0ml"
+	if got := buf.String(); got != want {
+		t.Errorf("writeHighlighted = %q, want %q", got, want)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 3); got != "hél" {
+		t.Errorf("truncate = %q, want %q", got, "hél")
+	}
+	if got := truncate("hi", 0); got != "" {
+		t.Errorf("truncate = %q, want empty", got)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !windows
+
+package picker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// terminal puts the TTY on stdin into raw mode for the lifetime of a picker
+type terminal struct {
+	saved string
+}
+
+// openTerminal switches stdin to raw mode, returning an error if it is not
+// a terminal that supports it
+func openTerminal() (*terminal, error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return &terminal{saved: saved}, nil
+}
+
+// restore puts the terminal back into the mode it was in before
+func (t *terminal) restore() {
+	_, _ = stty(t.saved)
+}
+
+// size returns the terminal's rows and columns
+func (t *terminal) size() (int, int) {
+	out, err := stty("size")
+	if err != nil {
+		return 24, 80
+	}
+	var rows, cols int
+	if _, err := fmt.Sscanf(out, "%d %d", &rows, &cols); err != nil || rows <= 0 || cols <= 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+// stty runs stty against the terminal on stdin
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s failed: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
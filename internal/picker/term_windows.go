@@ -0,0 +1,18 @@
+//go:build windows
+
+package picker
+
+import "errors"
+
+// terminal is not supported on Windows; callers fall back to a numbered prompt
+type terminal struct{}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("interactive picker is not supported on Windows")
+}
+
+func (t *terminal) restore() {}
+
+func (t *terminal) size() (int, int) {
+	return 24, 80
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CompleteCommand is the hidden wt command the completion scripts call
+// with the words typed so far, the word being completed last. It prints
+// one candidate per line, optionally followed by a tab and a description.
+const CompleteCommand = "__complete"
+
+const bashCompletion = `# wt completion for bash
+# Add to ~/.bashrc: eval "$(wt completion bash)"
+_wt_complete() {
+  local IFS=$'\n'
+  local candidates
+  candidates="$(command wt __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null | cut -f1)"
+  COMPREPLY=($(compgen -W "$candidates" -- "${COMP_WORDS[COMP_CWORD]}"))
+}
+complete -F _wt_complete wt
+`
+
+const zshCompletion = `# wt completion for zsh
+# Add to ~/.zshrc, after compinit: eval "$(wt completion zsh)"
+_wt() {
+  local -a candidates
+  local line name desc
+  for line in "${(@f)$(command wt __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+    [[ -n $line ]] || continue
+    name=${line%%$'\t'*}
+    desc=""
+    [[ $line == *$'\t'* ]] && desc=${line#*$'\t'}
+    candidates+=("${name//:/\\:}:$desc")
+  done
+  _describe -t values wt candidates
+}
+compdef _wt wt
+`
+
+const fishCompletion = `# wt completion for fish
+# Add to ~/.config/fish/config.fish: wt completion fish | source
+function __wt_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    command wt __complete $tokens[2..-1] "$current" 2>/dev/null
+end
+complete -c wt -f -a '(__wt_complete)'
+`
+
+var completions = map[string]string{
+	"bash": bashCompletion,
+	"zsh":  zshCompletion,
+	"fish": fishCompletion,
+}
+
+// CompletionNames returns the shells with completion scripts, sorted
+func CompletionNames() []string {
+	names := make([]string, 0, len(completions))
+	for name := range completions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Completion returns the completion script for the named shell
+func Completion(name string) (string, error) {
+	script, ok := completions[name]
+	if !ok {
+		return "", fmt.Errorf("no completion for shell '%s' (supported: %s)", name, strings.Join(CompletionNames(), ", "))
+	}
+	return script, nil
+}
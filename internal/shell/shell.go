@@ -0,0 +1,133 @@
+// Package shell generates the shell integration that lets wt change the
+// directory of the calling shell.
+//
+// A child process can't change its parent's directory, so each script
+// defines a wt function wrapping the binary. The function creates a temp
+// file and passes its path in CDFileEnv; wt writes the directory to enter
+// there and the function cds to it afterwards. stdout and stderr are left
+// alone, so prompts, the picker and colors keep working.
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CDFileEnv names the environment variable holding the file wt writes the
+// directory to enter to
+const CDFileEnv = "WT_CD_FILE"
+
+const posixScript = `# wt shell integration for %[1]s
+# Add to ~/.%[1]src: eval "$(wt init %[1]s)"
+wt() {
+  local cd_file exit_code target
+  cd_file="$(mktemp "${TMPDIR:-/tmp}/wt.XXXXXX")" || return
+  WT_CD_FILE="$cd_file" command wt "$@"
+  exit_code=$?
+  if [ -s "$cd_file" ]; then
+    target="$(cat -- "$cd_file")"
+    builtin cd -- "$target" || exit_code=$?
+  fi
+  rm -f -- "$cd_file"
+  return $exit_code
+}
+`
+
+const fishScript = `# wt shell integration for fish
+# Add to ~/.config/fish/config.fish: wt init fish | source
+function wt
+    set -l cd_file (mktemp -t wt.XXXXXX)
+    or return
+    WT_CD_FILE=$cd_file command wt $argv
+    set -l exit_code $status
+    if test -s $cd_file
+        set -l target (string collect < $cd_file)
+        builtin cd -- $target
+        or set exit_code $status
+    end
+    rm -f -- $cd_file
+    return $exit_code
+end
+`
+
+// pwshScript runs wt by the path it was generated with, %[1]s, since
+// Windows Terminal installs a wt.exe that usually comes first on PATH
+const pwshScript = `# wt shell integration for PowerShell
+# Add to $PROFILE: Invoke-Expression (& %[1]s init pwsh | Out-String)
+function wt {
+    $cdFile = [System.IO.Path]::GetTempFileName()
+    $previous = $env:WT_CD_FILE
+    $env:WT_CD_FILE = $cdFile
+    try {
+        & %[1]s @args
+        $exitCode = $LASTEXITCODE
+        $target = Get-Content -LiteralPath $cdFile -Raw
+        if ($target) {
+            Set-Location -LiteralPath $target
+        }
+    } finally {
+        $env:WT_CD_FILE = $previous
+        Remove-Item -LiteralPath $cdFile -ErrorAction SilentlyContinue
+    }
+    $global:LASTEXITCODE = $exitCode
+}
+`
+
+const nuScript = `# wt shell integration for nushell
+# Save to a file and source it from config.nu:
+#   wt init nu | save -f ~/.config/nushell/wt.nu
+#   source ~/.config/nushell/wt.nu
+def --env --wrapped wt [...args] {
+    let cd_file = (mktemp --tmpdir wt.XXXXXX)
+    # Keep wt's exit status whether or not nushell raises it as an error
+    let exit_code = try {
+        with-env { WT_CD_FILE: $cd_file } { ^wt ...$args; $env.LAST_EXIT_CODE }
+    } catch {|err|
+        $err.exit_code? | default 1
+    }
+    let target = (open --raw $cd_file)
+    rm -f $cd_file
+    if ($target | is-not-empty) {
+        cd $target
+    }
+    $env.LAST_EXIT_CODE = $exit_code
+}
+`
+
+var scripts = map[string]string{
+	"bash": fmt.Sprintf(posixScript, "bash"),
+	"zsh":  fmt.Sprintf(posixScript, "zsh"),
+	"fish": fishScript,
+	"pwsh": pwshScript,
+	"nu":   nuScript,
+}
+
+// Names returns the supported shells, sorted
+func Names() []string {
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Script returns the integration script for the named shell. exe is the
+// path of the wt binary, which the PowerShell script runs directly; the
+// others find wt on PATH.
+func Script(name, exe string) (string, error) {
+	script, ok := scripts[name]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell '%s' (supported: %s)", name, strings.Join(Names(), ", "))
+	}
+	if name == "pwsh" {
+		script = fmt.Sprintf(script, pwshQuote(exe))
+	}
+	return script, nil
+}
+
+// pwshQuote quotes s as a PowerShell string taken literally
+func pwshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
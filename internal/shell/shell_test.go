@@ -0,0 +1,65 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	exitStatus := map[string]string{
+		"bash": "return $exit_code",
+		"zsh":  "return $exit_code",
+		"fish": "return $exit_code",
+		"pwsh": "$global:LASTEXITCODE = $exitCode",
+		"nu":   "$env.LAST_EXIT_CODE = $exit_code",
+	}
+	for _, name := range []string{"bash", "zsh", "fish", "pwsh", "nu"} {
+		script, err := Script(name, "/usr/local/bin/wt")
+		if err != nil {
+			t.Errorf("Script(%q) error: %v", name, err)
+			continue
+		}
+		// The path must travel through the file, not stdout
+		if !strings.Contains(script, CDFileEnv) {
+			t.Errorf("Script(%q) doesn't set %s:\n%s", name, CDFileEnv, script)
+		}
+		if strings.Contains(script, "WT_CD_PATH") {
+			t.Errorf("Script(%q) parses WT_CD_PATH from stdout:\n%s", name, script)
+		}
+		// wt's exit status must reach the caller
+		if !strings.Contains(script, exitStatus[name]) {
+			t.Errorf("Script(%q) doesn't return wt's exit status with %q:\n%s", name, exitStatus[name], script)
+		}
+	}
+
+	// PowerShell runs the binary it was generated by, not the wt on PATH,
+	// which is usually Windows Terminal
+	script, err := Script("pwsh", `C:\Users\o'neil\bin\wt.exe`)
+	if err != nil {
+		t.Fatalf("Script(pwsh) error: %v", err)
+	}
+	if !strings.Contains(script, `& 'C:\Users\o''neil\bin\wt.exe' @args`) || strings.Contains(script, "Get-Command") {
+		t.Errorf("expected the PowerShell script to run the given binary:\n%s", script)
+	}
+
+	if _, err := Script("tcsh", ""); err == nil || !strings.Contains(err.Error(), "bash, fish, nu, pwsh, zsh") {
+		t.Errorf("expected an error listing supported shells, got %v", err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, name := range []string{"bash", "zsh", "fish"} {
+		script, err := Completion(name)
+		if err != nil {
+			t.Errorf("Completion(%q) error: %v", name, err)
+			continue
+		}
+		if !strings.Contains(script, "wt "+CompleteCommand) {
+			t.Errorf("Completion(%q) doesn't call wt %s:\n%s", name, CompleteCommand, script)
+		}
+	}
+
+	if _, err := Completion("nu"); err == nil {
+		t.Error("expected an error for a shell without completion")
+	}
+}
@@ -0,0 +1,286 @@
+// Package trash keeps what deleting a worktree would lose, its uncommitted
+// changes and untracked files, so that the worktree can be restored.
+package trash
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DirName is the name of the trash directory in the data directory
+	DirName = "trash"
+	// RefPrefix is where the commits of deleted worktrees are kept in
+	// their repository, so that git doesn't collect them
+	RefPrefix = "refs/wt-trash/"
+
+	entryFile     = "entry.json"
+	patchFile     = "changes.patch"
+	untrackedFile = "untracked.tar"
+)
+
+// Entry describes a deleted worktree in the trash
+type Entry struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+	// Head is the commit the worktree had checked out
+	Head    string    `json:"head"`
+	Deleted time.Time `json:"deleted"`
+	// Changes is set when there is a patch of changes to tracked files
+	Changes bool `json:"changes"`
+	// Untracked counts the saved untracked files
+	Untracked int `json:"untracked"`
+
+	dir string
+}
+
+// Ref returns the ref that keeps the entry's commit
+func (e Entry) Ref() string {
+	return RefPrefix + e.ID
+}
+
+// PatchPath returns the file holding the changes to tracked files, empty
+// if there were none
+func (e Entry) PatchPath() string {
+	if !e.Changes {
+		return ""
+	}
+	return filepath.Join(e.dir, patchFile)
+}
+
+// Expired reports whether the entry has been in the trash for more than
+// days days
+func (e Entry) Expired(days int, now time.Time) bool {
+	return now.Sub(e.Deleted) > time.Duration(days)*24*time.Hour
+}
+
+// Trash is a directory of deleted worktrees, one subdirectory each
+type Trash struct {
+	dir string
+}
+
+// Open returns the trash in the data directory dataDir
+func Open(dataDir string) *Trash {
+	return &Trash{dir: filepath.Join(dataDir, DirName)}
+}
+
+// Add saves a deleted worktree: patch holds the changes to its tracked
+// files, and untracked lists its untracked files relative to root. It
+// returns e with its ID set.
+func (t *Trash) Add(e Entry, patch []byte, root string, untracked []string, now time.Time) (Entry, error) {
+	if err := os.MkdirAll(t.dir, 0700); err != nil {
+		return e, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	// Worktrees deleted at the same time get the next free ID
+	for id := now.UnixNano(); ; id++ {
+		e.ID = strconv.FormatInt(id, 36)
+		e.dir = filepath.Join(t.dir, e.ID)
+		err := os.Mkdir(e.dir, 0700)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return e, fmt.Errorf("failed to create trash directory: %w", err)
+		}
+	}
+	e.Deleted = now
+
+	err := func() error {
+		if len(patch) > 0 {
+			if err := os.WriteFile(filepath.Join(e.dir, patchFile), patch, 0600); err != nil {
+				return err
+			}
+			e.Changes = true
+		}
+		if len(untracked) > 0 {
+			if err := writeTar(filepath.Join(e.dir, untrackedFile), root, untracked); err != nil {
+				return err
+			}
+			e.Untracked = len(untracked)
+		}
+		// The entry file goes last: without it, the directory is ignored
+		data, err := json.MarshalIndent(e, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(e.dir, entryFile), append(data, '\n'), 0600)
+	}()
+	if err != nil {
+		os.RemoveAll(e.dir)
+		return e, fmt.Errorf("failed to save '%s' to the trash: %w", e.Name, err)
+	}
+	return e, nil
+}
+
+// List returns the entries in the trash, most recently deleted first
+func (t *Trash) List() ([]Entry, error) {
+	dirs, err := os.ReadDir(t.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var entries []Entry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(t.dir, d.Name())
+		data, err := os.ReadFile(filepath.Join(dir, entryFile))
+		if err != nil {
+			// Left over from a deletion that failed halfway
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil || e.ID != d.Name() {
+			continue
+		}
+		e.dir = dir
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Deleted.After(entries[j].Deleted) })
+	return entries, nil
+}
+
+// Remove deletes an entry from the trash
+func (t *Trash) Remove(e Entry) error {
+	if err := os.RemoveAll(filepath.Join(t.dir, e.ID)); err != nil {
+		return fmt.Errorf("failed to remove '%s' from the trash: %w", e.Name, err)
+	}
+	return nil
+}
+
+// RestoreUntracked extracts the saved untracked files into root. Files
+// that already exist there are left alone and reported.
+func (e Entry) RestoreUntracked(root string) error {
+	if e.Untracked == 0 {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(e.dir, untrackedFile))
+	if err != nil {
+		return fmt.Errorf("failed to read untracked files: %w", err)
+	}
+	defer f.Close()
+
+	var existing []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read untracked files: %w", err)
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("invalid path '%s' in untracked files", hdr.Name)
+		}
+		target := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", hdr.Name, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, target)
+		case tar.TypeReg:
+			err = writeFile(target, tr, os.FileMode(hdr.Mode).Perm())
+		default:
+			continue
+		}
+		if errors.Is(err, os.ErrExist) {
+			existing = append(existing, hdr.Name)
+		} else if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", hdr.Name, err)
+		}
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("kept the existing %s instead of the saved one", strings.Join(existing, ", "))
+	}
+	return nil
+}
+
+// writeFile creates the file path with the contents of r, failing if it
+// exists
+func writeFile(path string, r io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeTar archives files, relative to root, into a tar file at path.
+// Only regular files and symlinks are archived.
+func writeTar(path, root string, files []string) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(out)
+	for _, file := range files {
+		if err := addToTar(tw, root, file); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// addToTar adds the file at root/name to tw
+func addToTar(tw *tar.Writer, root, name string) error {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	case !info.Mode().IsRegular():
+		return nil
+	}
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if link != "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,122 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddAndList(t *testing.T) {
+	tr := Open(t.TempDir())
+	entries, err := tr.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty trash, got %v, %v", entries, err)
+	}
+
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "notes.txt"), []byte("todo\n"), 0640); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Symlink("sub/notes.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+
+	now := time.Now()
+	older, err := tr.Add(Entry{Name: "older", Head: "abc"}, nil, src, nil, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	e, err := tr.Add(Entry{Name: "api", Branch: "feature", Head: "def"}, []byte("patch\n"), src, []string{"sub/notes.txt", "link"}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(e.Ref(), RefPrefix) || e.ID == older.ID {
+		t.Errorf("expected distinct IDs under %s, got %s and %s", RefPrefix, e.Ref(), older.ID)
+	}
+
+	entries, err = tr.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "api" || entries[1].Name != "older" {
+		t.Fatalf("expected api then older, got %+v", entries)
+	}
+	api := entries[0]
+	if !api.Changes || api.Untracked != 2 || entries[1].PatchPath() != "" {
+		t.Errorf("unexpected saved state: %+v", api)
+	}
+	if data, err := os.ReadFile(api.PatchPath()); err != nil || string(data) != "patch\n" {
+		t.Errorf("expected the patch to be saved, got %q, %v", data, err)
+	}
+
+	dst := t.TempDir()
+	if err := api.RestoreUntracked(dst); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sub", "notes.txt")); err != nil || string(data) != "todo\n" {
+		t.Errorf("expected notes.txt to be restored, got %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "sub", "notes.txt")); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("expected the file mode to be restored, got %v", info.Mode())
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "sub/notes.txt" {
+		t.Errorf("expected the symlink to be restored, got %q, %v", link, err)
+	}
+	// Existing files are kept
+	if err := api.RestoreUntracked(dst); err == nil || !strings.Contains(err.Error(), "sub/notes.txt") {
+		t.Errorf("expected an error naming the existing file, got %v", err)
+	}
+
+	if err := tr.Remove(older); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries, _ := tr.List(); len(entries) != 1 {
+		t.Errorf("expected one entry left, got %+v", entries)
+	}
+}
+
+func TestAdd_SameTime(t *testing.T) {
+	tr := Open(t.TempDir())
+	now := time.Now()
+	a, err := tr.Add(Entry{Name: "a", Head: "abc"}, nil, "", nil, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := tr.Add(Entry{Name: "b", Head: "def"}, nil, "", nil, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.ID == b.ID {
+		t.Errorf("expected distinct IDs, got %s twice", a.ID)
+	}
+	if entries, err := tr.List(); err != nil || len(entries) != 2 {
+		t.Errorf("expected both entries, got %+v, %v", entries, err)
+	}
+}
+
+func TestList_SkipsIncomplete(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, DirName, "partial"), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	entries, err := Open(dataDir).List()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v, %v", entries, err)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	e := Entry{Deleted: now.Add(-48 * time.Hour)}
+	if e.Expired(3, now) {
+		t.Error("expected an entry deleted 2 days ago to be kept for 3 days")
+	}
+	if !e.Expired(1, now) {
+		t.Error("expected an entry deleted 2 days ago to expire after 1 day")
+	}
+}
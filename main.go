@@ -1,89 +1,117 @@
 package main
 
 import (
-	"flag"
-	"fmt"
 	"os"
 
 	"github.com/niczy/wt/internal/commands"
 )
 
-const usage = `wt - Git Worktree Manager
+// usage is the part of the top-level help after the command list
+const usage = `
+The flag forms of earlier versions still work: wt -c <name>, wt -d <query>,
+wt -l, wt --history [N], wt --forget <name>, wt - and wt <query>.
+Run 'wt <command> -h' for the flags of a command.
 
-Usage:
-  wt -c <name>      Create a new worktree with the given name
-  wt -d <name>      Delete a worktree (fuzzy search)
-  wt -l             List all worktrees
-  wt <name>         Navigate to a worktree (fuzzy search)
+Search Syntax:
+  Queries use fzf's syntax; every space-separated term must match the
+  directory or branch name:
+    abc     fuzzy match           'abc    contains "abc"
+    ^abc    starts with "abc"     abc$    ends with "abc"
+    !abc    doesn't contain "abc" (also !^abc and !abc$)
+  Terms ignore case unless they contain an uppercase letter.
+
+Common Flags:
+  --json            Write results and errors as JSON to stdout
+  --porcelain       Write results and errors as stable "key value" lines
+                    (all commands except config, init and completion)
+  --color <when>    Highlight matched characters when choosing a worktree
+                    (go and rm): auto, always or never
+  --no-input        Never prompt (go, rm and prune): fail when several
+                    worktrees match, and delete only with --yes
+
+Exit Status:
+  0 success, 1 other error, 2 invalid input, 3 no match, 4 ambiguous match,
+  5 no worktrees, 6 cancelled, 7 --yes required, 8 unsaved work, 9 locked,
+  10 already exists, 11 not a git repository, 12 config error,
+  13 hook failed, 14 git failed
 
 Environment Variables:
   WT_HOME           Directory where worktrees are stored (default: ~/worktrees)
+  WT_PATH_TEMPLATE  Worktree path template (default: {repo}-{name} under WT_HOME)
+                    Placeholders: {wt_home} {repo} {repo_parent} {name}
+                    {branch} {user} {date}
+  WT_BASE_REF       Ref new branches start at (default: current HEAD)
+  WT_CONFIRM_DELETE Ask before deleting a worktree (default: true)
+  WT_DELETE_BRANCH  Delete a worktree's branch with it if merged into the base
+                    (default: false)
+  WT_NONINTERACTIVE Never prompt: fail when several worktrees match, and
+                    delete only with --yes (default: false)
+  WT_COLOR          Highlight matches: auto, always or never (default: auto)
+  NO_COLOR          Disable color when WT_COLOR is auto
+  WT_IGNORE_ACCENTS Let unaccented query letters match accented ones (default: true)
+  WT_FRECENCY       Rank often and recently visited worktrees first (default: true)
+  WT_PRESERVE_SUBDIR
+                    Enter the same subdirectory in the target worktree, or its
+                    nearest existing parent (default: true)
+  WT_TRASH_DAYS     Days deleted worktrees are kept for wt undo, 0 to delete
+                    right away (default: 30)
+  WT_DATA_DIR       Directory for navigation history and the trash
+                    (default: ~/.local/share/wt)
+  WT_CONFIG         User config file (default: ~/.config/wt/config.toml)
+
+Configuration:
+  Settings are read from the user config file and from .wt.toml at the repo
+  root. Precedence: flags > environment > .wt.toml > user config > defaults.
+  Hooks, wt_home, data_dir and path_template are only read from the user
+  config and the environment, never from .wt.toml.
 
 Examples:
-  wt -c feature-x   Create worktree at $WT_HOME/{repo}-feature-x
+  wt create feature-x
+                    Create worktree at $WT_HOME/{repo}-feature-x
+  wt create fix --from origin/main
+                    Create branch 'fix' off origin/main in a new worktree
+  wt create login --branch feature/JIRA-123-login
+                    Create worktree at $WT_HOME/{repo}-login on that branch
   wt feat           Navigate to worktree matching "feat"
-  wt -d feature     Delete worktree matching "feature"
+  wt rm feature     Delete worktree matching "feature"
+  wt rm api docs    Delete the worktrees matching "api" and "docs"
+  wt rm --all-matching feat
+                    Delete every worktree matching "feat"
+  wt prune --dry-run
+                    List worktrees whose branch is merged or gone
+  wt api '!old'     Navigate to a worktree matching "api" but not "old"
+  wt go ls          Navigate to a worktree matching "ls" rather than listing
+  wt back           Jump back to the worktree you were in before (also wt -)
+  wt history 2      Go to the second most recently visited worktree
+  wt undo           Restore the worktree deleted last, with its changes
 
 Shell Integration:
-  To enable 'cd' functionality, add this to your shell config:
-
-  # For bash/zsh:
-  wt() {
-    local output
-    output=$(command wt "$@")
-    local exit_code=$?
-    
-    # Check if output contains WT_CD_PATH
-    if echo "$output" | grep -q "^WT_CD_PATH="; then
-      local target_path
-      target_path=$(echo "$output" | grep "^WT_CD_PATH=" | cut -d= -f2)
-      echo "$output" | grep -v "^WT_CD_PATH="
-      cd "$target_path"
-    else
-      echo "$output"
-    fi
-    
-    return $exit_code
-  }
-`
-
-func main() {
-	createFlag := flag.String("c", "", "Create a new worktree with the given name")
-	deleteFlag := flag.String("d", "", "Delete a worktree (fuzzy search)")
-	listFlag := flag.Bool("l", false, "List all worktrees")
-	helpFlag := flag.Bool("h", false, "Show help")
+  wt can only change your shell's directory through a wrapper function.
+  Add one of these to your shell config:
 
-	flag.Usage = func() {
-		fmt.Print(usage)
-	}
+    bash        eval "$(wt init bash)"          (~/.bashrc)
+    zsh         eval "$(wt init zsh)"           (~/.zshrc)
+    fish        wt init fish | source           (config.fish)
+    PowerShell  Invoke-Expression (& wt init pwsh | Out-String)   ($PROFILE)
+    nushell     wt init nu | save -f ~/.config/nushell/wt.nu
+                and add "source ~/.config/nushell/wt.nu" to config.nu
 
-	flag.Parse()
+  For tab completion of flags, worktree names, branches and config keys:
 
-	if *helpFlag {
-		flag.Usage()
-		os.Exit(0)
-	}
-
-	var err error
-
-	switch {
-	case *createFlag != "":
-		err = commands.Create(*createFlag)
-	case *deleteFlag != "":
-		err = commands.Delete(*deleteFlag)
-	case *listFlag:
-		err = commands.List()
-	case flag.NArg() == 1:
-		err = commands.Navigate(flag.Arg(0))
-	case flag.NArg() == 0:
-		err = commands.List()
-	default:
-		flag.Usage()
-		os.Exit(1)
-	}
+    bash        eval "$(wt completion bash)"    (~/.bashrc)
+    zsh         eval "$(wt completion zsh)"     (~/.zshrc, after compinit)
+    fish        wt completion fish | source     (config.fish)
+`
 
+func main() {
+	args, err := rewriteLegacy(os.Args[1:])
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		err = &commands.Error{Code: commands.CodeInvalidInput, Err: err}
+		commands.ReportError(commands.FormatText, err)
+		os.Exit(commands.ExitCode(err))
 	}
+
+	// rewriteLegacy only returns invocations that start with a command
+	cmd, _ := lookupCommand(args[0])
+	os.Exit(runCommand(cmd, args[1:]))
 }